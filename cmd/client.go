@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/client"
+)
+
+const defaultAPIURL = "http://localhost:3000/api/v1"
+
+var credentialsFile string
+
+// credentials is what login stores for the other client commands.
+type credentials struct {
+	URL     string          `json:"url"`
+	Session *client.Session `json:"session"`
+}
+
+func init() {
+	clientCmd := &cobra.Command{
+		Use:   "client",
+		Short: "Call the pyvinci API of a running server",
+
+		// the client does not need the server config
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+	clientCmd.PersistentFlags().StringVar(
+		&credentialsFile,
+		"credentials",
+		"",
+		"credentials file (default is $XDG_CONFIG_HOME/pyvinci/credentials.json)",
+	)
+
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in and store the credentials for the other commands",
+		Args:  cobra.NoArgs,
+		Run:   runClientLoginCommand,
+	}
+	loginCmd.Flags().String("url", defaultAPIURL, "Base URL of the API")
+	loginCmd.Flags().StringP("username", "u", "", "Username")
+	loginCmd.Flags().Bool("password-stdin", false, "Read the password from stdin")
+	clientCmd.AddCommand(loginCmd)
+
+	clientCmd.AddCommand(
+		newClientProjectsCommand(),
+		newClientImagesCommand(),
+		newClientJobsCommand(),
+		newClientExportCommand(),
+	)
+	rootCmd.AddCommand(clientCmd)
+}
+
+func runClientLoginCommand(cmd *cobra.Command, args []string) {
+	url, _ := cmd.Flags().GetString("url")
+	username, _ := cmd.Flags().GetString("username")
+	passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+
+	stdin := bufio.NewReader(os.Stdin)
+	if username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		username = readLine(stdin)
+	}
+
+	// the password is never taken as a flag so it does not end up in the
+	// shell history
+	password := os.Getenv("PYVINCI_PASSWORD")
+	if password == "" {
+		if !passwordStdin {
+			fmt.Fprint(os.Stderr, "Password: ")
+		}
+		password = readLine(stdin)
+	}
+
+	c := client.New(url)
+	session, err := c.Login(clientContext(), username, password)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := saveCredentials(&credentials{URL: url, Session: session}); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Logged in as %s\n", session.Username)
+}
+
+func readLine(r *bufio.Reader) string {
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalln(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func credentialsPath() (string, error) {
+	if credentialsFile != "" {
+		return credentialsFile, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pyvinci", "credentials.json"), nil
+}
+
+func saveCredentials(creds *credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// newClient returns a client logged in with the stored credentials.
+func newClient() *client.Client {
+	path, err := credentialsPath()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Fatalln("not logged in, run the client login command first")
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(b, &creds); err != nil {
+		log.Fatalf("reading %s: %v\n", path, err)
+	}
+	if creds.Session == nil {
+		log.Fatalln("not logged in, run the client login command first")
+	}
+
+	c := client.New(creds.URL)
+	c.SetSession(creds.Session)
+	return c
+}
+
+// clientContext is cancelled on interrupt, so commands can stop cleanly.
+func clientContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		cancel()
+	}()
+	return ctx
+}
+
+// fatalClientError exits with err, hinting at logging in again when the
+// stored token stopped working.
+func fatalClientError(err error) {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Code == apierror.CodeUnauthorized {
+		log.Fatalf("%v\nthe stored session is no longer valid, run the client login command again\n", err)
+	}
+	log.Fatalln(err)
+}
@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/caquillo07/pyvinci-server/pkg/client"
+)
+
+const (
+	// the server takes at most this many images per request
+	maxUploadBatchSize = 50
+
+	// how often the upload progress is printed
+	progressInterval = 200 * time.Millisecond
+)
+
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+func newClientImagesCommand() *cobra.Command {
+	imagesCmd := &cobra.Command{
+		Use:   "images",
+		Short: "Manage the images of a project",
+	}
+
+	uploadCmd := &cobra.Command{
+		Use:   "upload <dir>",
+		Short: "Upload every image of a directory to a project",
+		Long: `Upload every image of a directory to a project.
+
+The images already uploaded are tracked in a state file inside the directory,
+so an interrupted upload is resumed by running the same command again. Every
+batch is sent with an idempotency key derived from its files, so a batch the
+server stored before the upload was interrupted is not stored twice when it
+is sent again with the same batch size.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runClientImagesUploadCommand,
+	}
+	uploadCmd.Flags().StringP("project", "p", "", "ID of the project")
+	uploadCmd.Flags().IntP("concurrency", "c", 4, "How many requests are sent at once")
+	uploadCmd.Flags().Int("batch-size", 10, "How many images are sent per request, up to 50")
+	uploadCmd.Flags().Bool("restart", false, "Upload every image again, ignoring the state file")
+	_ = uploadCmd.MarkFlagRequired("project")
+
+	imagesCmd.AddCommand(uploadCmd)
+	return imagesCmd
+}
+
+// uploadState tracks the images of a directory already uploaded to a
+// project, by file name.
+type uploadState struct {
+	path string
+	mu   sync.Mutex
+
+	ProjectID string `json:"projectId"`
+
+	// UploadID is part of the idempotency key of every batch, a new one is
+	// picked on restart so the images are really uploaded again
+	UploadID string            `json:"uploadId"`
+	Uploaded map[string]string `json:"uploaded"`
+}
+
+func uploadStatePath(dir, projectID string) string {
+	return filepath.Join(dir, ".pyvinci-upload-"+projectID+".json")
+}
+
+func loadUploadState(dir, projectID string, restart bool) (*uploadState, error) {
+	state := &uploadState{
+		path:      uploadStatePath(dir, projectID),
+		ProjectID: projectID,
+		Uploaded:  map[string]string{},
+	}
+	if !restart {
+		b, err := ioutil.ReadFile(state.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, state); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", state.path, err)
+			}
+		}
+	}
+
+	// the upload ID is saved before anything is uploaded, so batches sent
+	// before an interruption get the same keys when sent again
+	if state.UploadID == "" {
+		state.UploadID = uuid.Must(uuid.NewV4()).String()
+		if err := state.save(); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// add records the uploaded images and saves the state.
+func (s *uploadState) add(names []string, images []*client.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the server returns the images in the order they were sent
+	for i, img := range images {
+		if i < len(names) {
+			s.Uploaded[names[i]] = img.ID
+		}
+	}
+	return s.save()
+}
+
+func (s *uploadState) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, b, 0644)
+}
+
+// batchKey is the idempotency key of a batch, the same files sent again in
+// the same upload get the same key.
+func (s *uploadState) batchKey(batch []uploadFile) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", s.ProjectID, s.UploadID)
+	for _, f := range batch {
+		fmt.Fprintf(h, "%s\x00%d\n", f.name, f.size)
+	}
+	return "upload-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// uploadFile is an image of the directory waiting to be uploaded.
+type uploadFile struct {
+	name string
+	path string
+	size int64
+}
+
+// uploadProgress counts the bytes read from the files being uploaded.
+type uploadProgress struct {
+	totalFiles int
+	totalBytes int64
+	files      int64
+	bytes      int64
+}
+
+func (p *uploadProgress) print() {
+	files := atomic.LoadInt64(&p.files)
+	bytes := atomic.LoadInt64(&p.bytes)
+	percent := 100.0
+	if p.totalBytes > 0 {
+		percent = float64(bytes) / float64(p.totalBytes) * 100
+	}
+	fmt.Fprintf(
+		os.Stderr,
+		"\rUploaded %d/%d images, %s / %s (%.0f%%)   ",
+		files, p.totalFiles, formatBytes(bytes), formatBytes(p.totalBytes), percent,
+	)
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *uploadProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	atomic.AddInt64(&r.progress.bytes, int64(n))
+	return n, err
+}
+
+func runClientImagesUploadCommand(cmd *cobra.Command, args []string) {
+	dir := args[0]
+	projectID, _ := cmd.Flags().GetString("project")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	restart, _ := cmd.Flags().GetBool("restart")
+
+	if concurrency < 1 {
+		log.Fatalln("concurrency must be at least 1")
+	}
+	if batchSize < 1 || batchSize > maxUploadBatchSize {
+		log.Fatalf("batch-size must be between 1 and %d\n", maxUploadBatchSize)
+	}
+
+	state, err := loadUploadState(dir, projectID, restart)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	files, err := pendingUploads(dir, state)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(files) == 0 {
+		fmt.Printf("Nothing to upload, %d images already uploaded\n", len(state.Uploaded))
+		return
+	}
+
+	c := newClient()
+	ctx, cancel := context.WithCancel(clientContext())
+	defer cancel()
+
+	// fail early on a wrong project instead of once per batch
+	if _, err := c.GetProject(ctx, projectID); err != nil {
+		fatalClientError(err)
+	}
+
+	progress := &uploadProgress{totalFiles: len(files)}
+	for _, f := range files {
+		progress.totalBytes += f.size
+	}
+
+	batches := make(chan []uploadFile)
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(files); start += batchSize {
+			end := start + batchSize
+			if end > len(files) {
+				end = len(files)
+			}
+			select {
+			case batches <- files[start:end]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var uploadErr error
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := uploadBatch(ctx, c, projectID, batch, state, progress); err != nil {
+					errOnce.Do(func() {
+						uploadErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+wait:
+	for {
+		select {
+		case <-done:
+			break wait
+		case <-ticker.C:
+			progress.print()
+		}
+	}
+	progress.print()
+	fmt.Fprintln(os.Stderr)
+
+	if uploadErr == nil && ctx.Err() != nil {
+		uploadErr = ctx.Err()
+	}
+	if uploadErr != nil {
+		left := len(files) - int(atomic.LoadInt64(&progress.files))
+		fmt.Fprintf(os.Stderr, "%d images were not uploaded, run the command again to resume\n", left)
+		fatalClientError(uploadErr)
+	}
+	fmt.Printf("Uploaded %d images to project %s\n", len(files), projectID)
+}
+
+// pendingUploads lists the images of dir not uploaded yet, sorted by name.
+func pendingUploads(dir string, state *uploadState) ([]uploadFile, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []uploadFile
+	for _, e := range entries {
+		if e.IsDir() || !imageExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		if _, ok := state.Uploaded[e.Name()]; ok {
+			continue
+		}
+		files = append(files, uploadFile{
+			name: e.Name(),
+			path: filepath.Join(dir, e.Name()),
+			size: e.Size(),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	return files, nil
+}
+
+func uploadBatch(
+	ctx context.Context,
+	c *client.Client,
+	projectID string,
+	batch []uploadFile,
+	state *uploadState,
+	progress *uploadProgress,
+) error {
+	uploads := make([]client.Upload, 0, len(batch))
+	names := make([]string, 0, len(batch))
+	for _, f := range batch {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		uploads = append(uploads, client.Upload{
+			Name:   f.name,
+			Reader: &progressReader{r: file, progress: progress},
+		})
+		names = append(names, f.name)
+	}
+
+	images, err := c.UploadImages(ctx, projectID, uploads, client.UploadImagesOptions{
+		IdempotencyKey: state.batchKey(batch),
+	})
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&progress.files, int64(len(images)))
+	return state.add(names, images)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
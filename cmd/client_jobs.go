@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/caquillo07/pyvinci-server/pkg/client"
+)
+
+func newClientJobsCommand() *cobra.Command {
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Start and follow the jobs of a project",
+	}
+
+	startCmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start a job for a project",
+		Args:  cobra.NoArgs,
+		Run:   runClientJobsStartCommand,
+	}
+	startCmd.Flags().StringP("project", "p", "", "ID of the project")
+	startCmd.Flags().Int("priority", 0, "Priority of the job in the queue, higher goes first")
+	startCmd.Flags().BoolP("watch", "w", false, "Follow the job until it is done")
+	_ = startCmd.MarkFlagRequired("project")
+
+	watchCmd := &cobra.Command{
+		Use:   "watch <job-id>",
+		Short: "Follow the status and progress of a job until it is done",
+		Args:  cobra.ExactArgs(1),
+		Run:   runClientJobsWatchCommand,
+	}
+	watchCmd.Flags().StringP("project", "p", "", "ID of the project")
+	_ = watchCmd.MarkFlagRequired("project")
+
+	jobsCmd.AddCommand(startCmd, watchCmd)
+	return jobsCmd
+}
+
+func runClientJobsStartCommand(cmd *cobra.Command, args []string) {
+	projectID, _ := cmd.Flags().GetString("project")
+	priority, _ := cmd.Flags().GetInt("priority")
+	watch, _ := cmd.Flags().GetBool("watch")
+
+	c := newClient()
+	ctx := clientContext()
+	job, err := c.StartJob(ctx, projectID, client.StartJobOptions{Priority: priority})
+	if err != nil {
+		fatalClientError(err)
+	}
+
+	if !watch {
+		fmt.Println(job.JobID)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Started job %s\n", job.JobID)
+	watchJob(ctx, c, projectID, job.JobID)
+}
+
+func runClientJobsWatchCommand(cmd *cobra.Command, args []string) {
+	projectID, _ := cmd.Flags().GetString("project")
+	watchJob(clientContext(), newClient(), projectID, args[0])
+}
+
+// watchJob prints the events of the job until it is done, exiting with an
+// error when the job failed.
+func watchJob(ctx context.Context, c *client.Client, projectID, jobID string) {
+	var last *client.JobEvent
+	err := c.WatchJob(ctx, projectID, jobID, func(e *client.JobEvent) error {
+		last = e
+		if e.Progress != nil {
+			fmt.Printf("%s %s %d%%\n", e.CreatedAt.Local().Format("15:04:05"), e.Status, *e.Progress)
+		} else {
+			fmt.Printf("%s %s\n", e.CreatedAt.Local().Format("15:04:05"), e.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		fatalClientError(err)
+	}
+
+	if last != nil && last.Status == client.JobStatusFailed {
+		job, err := c.GetJob(ctx, projectID, jobID)
+		if err != nil {
+			fatalClientError(err)
+		}
+		log.Fatalf("job failed: %s\n", job.Error)
+	}
+}
+
+func newClientExportCommand() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Download the annotations of a project as COCO JSON or Pascal VOC",
+		Args:  cobra.NoArgs,
+		Run:   runClientExportCommand,
+	}
+	exportCmd.Flags().StringP("project", "p", "", "ID of the project")
+	exportCmd.Flags().StringP("format", "f", client.FormatCOCO, "Format of the annotations, coco or voc")
+	exportCmd.Flags().StringP("output", "o", "", "File to write (default <project>-<format>.json or .zip)")
+	_ = exportCmd.MarkFlagRequired("project")
+	return exportCmd
+}
+
+func runClientExportCommand(cmd *cobra.Command, args []string) {
+	projectID, _ := cmd.Flags().GetString("project")
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+
+	if output == "" {
+		output = projectID + "-" + format + ".json"
+		if format == client.FormatVOC {
+			output = projectID + "-" + format + ".zip"
+		}
+	}
+
+	body, err := newClient().ExportAnnotations(clientContext(), projectID, format)
+	if err != nil {
+		fatalClientError(err)
+	}
+	defer body.Close()
+
+	f, err := os.Create(output)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		log.Fatalln(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(output)
+}
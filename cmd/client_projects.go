@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/caquillo07/pyvinci-server/pkg/client"
+)
+
+func newClientProjectsCommand() *cobra.Command {
+	projectsCmd := &cobra.Command{
+		Use:   "projects",
+		Short: "Manage projects",
+	}
+
+	lsCmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List the projects",
+		Args:  cobra.NoArgs,
+		Run:   runClientProjectsLsCommand,
+	}
+	lsCmd.Flags().String("name", "", "Only projects whose name starts with this")
+	lsCmd.Flags().String("keyword", "", "Only projects with this keyword")
+	lsCmd.Flags().String("status", "", "Only projects whose latest job has this status")
+
+	createCmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a project",
+		Args:  cobra.ExactArgs(1),
+		Run:   runClientProjectsCreateCommand,
+	}
+	createCmd.Flags().StringSliceP("keywords", "k", nil, "Keywords of the project, comma separated")
+
+	projectsCmd.AddCommand(lsCmd, createCmd, &cobra.Command{
+		Use:   "rm <project-id>...",
+		Short: "Delete projects",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runClientProjectsRmCommand,
+	})
+	return projectsCmd
+}
+
+func runClientProjectsLsCommand(cmd *cobra.Command, args []string) {
+	c := newClient()
+	ctx := clientContext()
+
+	opts := client.ListProjectsOptions{}
+	opts.NamePrefix, _ = cmd.Flags().GetString("name")
+	opts.Keyword, _ = cmd.Flags().GetString("keyword")
+	opts.Status, _ = cmd.Flags().GetString("status")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tKEYWORDS\tCREATED")
+	for {
+		page, err := c.ListProjects(ctx, opts)
+		if err != nil {
+			fatalClientError(err)
+		}
+		for _, p := range page.Projects {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n",
+				p.ID, p.Name, p.Status, p.Keywords, p.CreatedAt.Local().Format(time.RFC822))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	w.Flush()
+}
+
+func runClientProjectsCreateCommand(cmd *cobra.Command, args []string) {
+	keywords, _ := cmd.Flags().GetStringSlice("keywords")
+
+	project, err := newClient().CreateProject(clientContext(), args[0], keywords)
+	if err != nil {
+		fatalClientError(err)
+	}
+	fmt.Println(project.ID)
+}
+
+func runClientProjectsRmCommand(cmd *cobra.Command, args []string) {
+	c := newClient()
+	ctx := clientContext()
+	for _, id := range args {
+		if err := c.DeleteProject(ctx, id); err != nil {
+			fatalClientError(err)
+		}
+		fmt.Println(id)
+	}
+}
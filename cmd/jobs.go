@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/conf"
+	"github.com/caquillo07/pyvinci-server/pkg/jobs"
+)
+
+func init() {
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Manage the processing jobs",
+	}
+	jobsCmd.AddCommand(&cobra.Command{
+		Use:   "reap",
+		Short: "Re-queue or fail the jobs that are stuck",
+		Run:   runJobsReapCommand,
+	})
+	rootCmd.AddCommand(jobsCmd)
+}
+
+func runJobsReapCommand(cmd *cobra.Command, args []string) {
+	config, err := conf.LoadConfig(viper.GetViper())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	db, err := database.Open(config.Database)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	reaped, err := jobs.NewReaper(db, config.Jobs).Reap()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	zap.L().Info("jobs reaped", zap.Int("count", reaped))
+}
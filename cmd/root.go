@@ -19,8 +19,12 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	cobra.OnInitialize(func() { conf.InitViper(configFile) })
-	cobra.OnInitialize(initLogging)
+	// sub-commands that do not need the server config, such as the API
+	// client, override this
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		conf.InitViper(configFile)
+		initLogging()
+	}
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/conf"
+	"github.com/caquillo07/pyvinci-server/pkg/worker"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "worker",
+		Short: "Run an embedded worker that processes the queued jobs",
+		Run:   runWorkerCommand,
+	}
+	cmd.Flags().String("processor", "", "Processor the jobs are handed to (default from config)")
+	cmd.Flags().Int("concurrency", 0, "How many jobs are processed at once (default from config)")
+	rootCmd.AddCommand(cmd)
+}
+
+func runWorkerCommand(cmd *cobra.Command, args []string) {
+	config, err := conf.LoadConfig(viper.GetViper())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if cmd.Flags().Changed("processor") {
+		if config.Worker.Processor, err = cmd.Flags().GetString("processor"); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if cmd.Flags().Changed("concurrency") {
+		if config.Worker.Concurrency, err = cmd.Flags().GetInt("concurrency"); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	processor, err := worker.NewProcessor(config.Worker.Processor)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	db, err := database.Open(config.Database)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// let the jobs in progress finish before exiting
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		zap.L().Info("stopping worker, waiting for jobs in progress")
+		cancel()
+	}()
+
+	zap.L().Info(
+		"worker started",
+		zap.String("processor", config.Worker.Processor),
+		zap.Int("concurrency", config.Worker.Concurrency),
+	)
+	worker.New(db, config.Worker, processor).Run(ctx)
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
@@ -46,3 +47,13 @@ func Transact(ctx context.Context, db *gorm.DB, f func(ctx context.Context, tx *
 
 	return tx.Commit().Error
 }
+
+// Now returns the current time of the database, which is what the times
+// set by the database itself are compared to.
+func Now(db *gorm.DB) (time.Time, error) {
+	var now time.Time
+	if err := db.Raw("SELECT now()").Row().Scan(&now); err != nil {
+		return time.Time{}, err
+	}
+	return now, nil
+}
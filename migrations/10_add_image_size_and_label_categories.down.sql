@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS label_category;
+ALTER TABLE image DROP COLUMN height;
+ALTER TABLE image DROP COLUMN width;
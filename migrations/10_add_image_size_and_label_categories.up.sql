@@ -0,0 +1,10 @@
+ALTER TABLE image ADD COLUMN width INTEGER;
+ALTER TABLE image ADD COLUMN height INTEGER;
+
+-- gives every label a stable numeric ID, used by the annotation exports
+CREATE TABLE label_category
+(
+    id         SERIAL PRIMARY KEY,
+    name       TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT now()
+);
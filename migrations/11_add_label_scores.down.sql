@@ -0,0 +1 @@
+ALTER TABLE image DROP COLUMN label_scores;
@@ -0,0 +1,2 @@
+-- confidence of the model for each label, from 0 to 1, keyed by label
+ALTER TABLE image ADD COLUMN label_scores JSONB;
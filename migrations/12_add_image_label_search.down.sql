@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_project_user_record;
+DROP INDEX IF EXISTS idx_image_project_id;
+DROP INDEX IF EXISTS idx_image_labels_all;
+ALTER TABLE image DROP COLUMN labels_all;
@@ -0,0 +1,12 @@
+-- every label of an image in a single array, so it can be searched with one
+-- GIN index regardless of the category of the label
+ALTER TABLE image
+    ADD COLUMN labels_all TEXT ARRAY GENERATED ALWAYS AS (
+                coalesce(labels_things, '{}'::text[]) ||
+                coalesce(labels_stuff, '{}'::text[]) ||
+                coalesce(masks_labels, '{}'::text[])
+        ) STORED;
+
+CREATE INDEX idx_image_labels_all on image USING GIN (labels_all);
+CREATE INDEX idx_image_project_id on image (project_id);
+CREATE INDEX idx_project_user_record on project (user_record);
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS image_label_edit;
+
+DROP INDEX IF EXISTS idx_image_labels_all;
+ALTER TABLE image DROP COLUMN labels_all;
+ALTER TABLE image
+    ADD COLUMN labels_all TEXT ARRAY GENERATED ALWAYS AS (
+                coalesce(labels_things, '{}'::text[]) ||
+                coalesce(labels_stuff, '{}'::text[]) ||
+                coalesce(masks_labels, '{}'::text[])
+        ) STORED;
+CREATE INDEX idx_image_labels_all on image USING GIN (labels_all);
+
+ALTER TABLE image DROP COLUMN user_labels_stuff;
+ALTER TABLE image DROP COLUMN user_labels_things;
@@ -0,0 +1,29 @@
+-- labels corrected by the user, kept apart from the model output. When null
+-- the image was never corrected and the model output is used as is.
+ALTER TABLE image ADD COLUMN user_labels_things TEXT ARRAY;
+ALTER TABLE image ADD COLUMN user_labels_stuff TEXT ARRAY;
+
+-- searches and statistics are done over the corrected labels
+DROP INDEX IF EXISTS idx_image_labels_all;
+ALTER TABLE image DROP COLUMN labels_all;
+ALTER TABLE image
+    ADD COLUMN labels_all TEXT ARRAY GENERATED ALWAYS AS (
+                coalesce(user_labels_things, labels_things, '{}'::text[]) ||
+                coalesce(user_labels_stuff, labels_stuff, '{}'::text[]) ||
+                coalesce(masks_labels, '{}'::text[])
+        ) STORED;
+CREATE INDEX idx_image_labels_all on image USING GIN (labels_all);
+
+CREATE TABLE image_label_edit
+(
+    id          uuid primary key default uuid_generate_v4(),
+    image_id    uuid REFERENCES image (id) ON DELETE CASCADE NOT NULL,
+    user_record uuid REFERENCES user_record (id)           NOT NULL,
+    category    TEXT                                       NOT NULL,
+    added       TEXT ARRAY                                 NOT NULL,
+    removed     TEXT ARRAY                                 NOT NULL,
+    created_at  TIMESTAMP                                  NOT NULL,
+    updated_at  TIMESTAMP                                  NOT NULL
+);
+
+CREATE INDEX idx_image_label_edit_image_id on image_label_edit (image_id, created_at);
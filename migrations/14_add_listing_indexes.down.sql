@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_project_id_created_at;
+DROP INDEX IF EXISTS idx_image_project_id_created_at;
+DROP INDEX IF EXISTS idx_project_user_record_created_at;
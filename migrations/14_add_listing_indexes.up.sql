@@ -0,0 +1,3 @@
+CREATE INDEX idx_project_user_record_created_at on project (user_record, created_at, id);
+CREATE INDEX idx_image_project_id_created_at on image (project_id, created_at, id);
+CREATE INDEX idx_jobs_project_id_created_at on jobs (project_id, created_at);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS image_bump_version ON image;
+DROP TRIGGER IF EXISTS project_bump_version ON project;
+DROP FUNCTION IF EXISTS bump_version();
+ALTER TABLE image DROP COLUMN version;
+ALTER TABLE project DROP COLUMN version;
@@ -0,0 +1,24 @@
+ALTER TABLE project ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE image ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Bumped on every update, including the ones made by the worker, so clients
+-- can detect concurrent changes.
+CREATE OR REPLACE FUNCTION bump_version() RETURNS TRIGGER AS
+$$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER project_bump_version
+    BEFORE UPDATE
+    ON project
+    FOR EACH ROW
+EXECUTE PROCEDURE bump_version();
+
+CREATE TRIGGER image_bump_version
+    BEFORE UPDATE
+    ON image
+    FOR EACH ROW
+EXECUTE PROCEDURE bump_version();
@@ -0,0 +1,17 @@
+CREATE TABLE idempotency_key
+(
+    user_record   uuid REFERENCES user_record (id) ON DELETE CASCADE NOT NULL,
+    key           TEXT                                               NOT NULL,
+    -- hash of the method, path and body of the first request
+    fingerprint   TEXT                                               NOT NULL,
+    -- null while the first request is still being processed
+    status_code   INTEGER,
+    content_type  TEXT,
+    response_body BYTEA,
+    expires_at    TIMESTAMP                                          NOT NULL,
+    created_at    TIMESTAMP                                          NOT NULL,
+    updated_at    TIMESTAMP                                          NOT NULL,
+    PRIMARY KEY (user_record, key)
+);
+
+CREATE INDEX idx_idempotency_key_expires_at on idempotency_key (expires_at);
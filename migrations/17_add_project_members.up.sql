@@ -0,0 +1,20 @@
+CREATE TABLE project_member
+(
+    project_id  uuid REFERENCES project (id) ON DELETE CASCADE      NOT NULL,
+    user_record uuid REFERENCES user_record (id) ON DELETE CASCADE  NOT NULL,
+    -- owners manage the members, editors change the project and viewers
+    -- only read it
+    role        TEXT                                                NOT NULL
+        CHECK (role IN ('owner', 'editor', 'viewer')),
+    invited_by  uuid REFERENCES user_record (id) ON DELETE SET NULL,
+    created_at  TIMESTAMP                                           NOT NULL,
+    updated_at  TIMESTAMP                                           NOT NULL,
+    PRIMARY KEY (project_id, user_record)
+);
+
+CREATE INDEX idx_project_member_user_record on project_member (user_record);
+
+-- the creators of the existing projects are their owners
+INSERT INTO project_member (project_id, user_record, role, created_at, updated_at)
+SELECT id, user_record, 'owner', created_at, created_at
+FROM project;
@@ -0,0 +1,17 @@
+CREATE TABLE share_link
+(
+    id               uuid primary key                                    default uuid_generate_v4(),
+    project_id       uuid REFERENCES project (id) ON DELETE CASCADE     NOT NULL,
+    created_by       uuid REFERENCES user_record (id) ON DELETE CASCADE NOT NULL,
+    token            TEXT UNIQUE                                        NOT NULL,
+    -- bcrypt hash, null when the link needs no password
+    password         TEXT,
+    expires_at       TIMESTAMP,
+    revoked_at       TIMESTAMP,
+    access_count     BIGINT                                             NOT NULL DEFAULT 0,
+    last_accessed_at TIMESTAMP,
+    created_at       TIMESTAMP                                          NOT NULL,
+    updated_at       TIMESTAMP                                          NOT NULL
+);
+
+CREATE INDEX idx_share_link_project_id on share_link (project_id, created_at);
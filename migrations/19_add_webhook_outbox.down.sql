@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS job_event_queue_webhooks ON job_event;
+DROP FUNCTION IF EXISTS job_event_queue_webhooks();
+DROP TABLE IF EXISTS webhook_job_event;
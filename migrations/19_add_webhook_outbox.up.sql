@@ -0,0 +1,23 @@
+-- Final job events waiting for their webhook deliveries to be queued. Rows
+-- are added in the same transaction as the job update, so events are never
+-- missed while no server is running or listening.
+CREATE TABLE webhook_job_event
+(
+    job_event_id BIGINT PRIMARY KEY REFERENCES job_event (id) ON DELETE CASCADE
+);
+
+CREATE OR REPLACE FUNCTION job_event_queue_webhooks() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NEW.status IN ('FINISHED', 'FAILED') THEN
+        INSERT INTO webhook_job_event (job_event_id) VALUES (NEW.id);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER job_event_queue_webhooks
+    AFTER INSERT
+    ON job_event
+    FOR EACH ROW
+EXECUTE PROCEDURE job_event_queue_webhooks();
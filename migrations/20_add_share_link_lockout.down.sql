@@ -0,0 +1,2 @@
+ALTER TABLE share_link DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE share_link DROP COLUMN IF EXISTS failed_attempts;
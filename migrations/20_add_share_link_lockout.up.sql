@@ -0,0 +1,4 @@
+-- wrong passwords in a row, the link is locked for a while once there are
+-- too many so its password can not be guessed
+ALTER TABLE share_link ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE share_link ADD COLUMN locked_until TIMESTAMP;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_image_labels_all;
+ALTER TABLE image DROP COLUMN labels_all;
+ALTER TABLE image
+    ADD COLUMN labels_all TEXT ARRAY GENERATED ALWAYS AS (
+                coalesce(user_labels_things, labels_things, '{}'::text[]) ||
+                coalesce(user_labels_stuff, labels_stuff, '{}'::text[]) ||
+                coalesce(masks_labels, '{}'::text[])
+        ) STORED;
+CREATE INDEX idx_image_labels_all on image USING GIN (labels_all);
@@ -0,0 +1,10 @@
+-- mask labels repeat the things they outline and are never corrected, so
+-- searches and statistics only consider the things and stuff of an image
+DROP INDEX IF EXISTS idx_image_labels_all;
+ALTER TABLE image DROP COLUMN labels_all;
+ALTER TABLE image
+    ADD COLUMN labels_all TEXT ARRAY GENERATED ALWAYS AS (
+                coalesce(user_labels_things, labels_things, '{}'::text[]) ||
+                coalesce(user_labels_stuff, labels_stuff, '{}'::text[])
+        ) STORED;
+CREATE INDEX idx_image_labels_all on image USING GIN (labels_all);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS jobs_stamp_timing ON jobs;
+DROP FUNCTION IF EXISTS jobs_stamp_timing();
+ALTER TABLE jobs DROP COLUMN finished_at;
+ALTER TABLE jobs DROP COLUMN started_at;
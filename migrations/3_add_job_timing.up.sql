@@ -0,0 +1,25 @@
+ALTER TABLE jobs ADD COLUMN started_at TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN finished_at TIMESTAMP;
+
+-- The worker only ever touches the status and result columns, so the timing
+-- is stamped here whenever the status moves forward.
+CREATE OR REPLACE FUNCTION jobs_stamp_timing() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status THEN
+        IF NEW.status <> 'PENDING_LABELS' AND NEW.started_at IS NULL THEN
+            NEW.started_at = now();
+        END IF;
+        IF NEW.status IN ('FINISHED', 'FAILED') AND NEW.finished_at IS NULL THEN
+            NEW.finished_at = now();
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER jobs_stamp_timing
+    BEFORE UPDATE
+    ON jobs
+    FOR EACH ROW
+EXECUTE PROCEDURE jobs_stamp_timing();
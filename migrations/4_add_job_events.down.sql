@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS jobs_record_event ON jobs;
+DROP FUNCTION IF EXISTS jobs_record_event();
+DROP TABLE IF EXISTS job_event;
+ALTER TABLE jobs DROP COLUMN progress;
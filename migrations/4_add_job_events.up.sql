@@ -0,0 +1,33 @@
+-- optional progress percentage (0-100) reported by the worker
+ALTER TABLE jobs ADD COLUMN progress INTEGER;
+
+CREATE TABLE job_event
+(
+    id         BIGSERIAL PRIMARY KEY,
+    job_id     uuid REFERENCES jobs (id) ON DELETE CASCADE NOT NULL,
+    status     TEXT                                       NOT NULL,
+    progress   INTEGER,
+    created_at TIMESTAMP                                  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_job_event_job_id on job_event (job_id, id);
+
+-- Every status or progress change on a job is recorded as an event, this is
+-- what allows clients streaming the job to resume where they left off.
+CREATE OR REPLACE FUNCTION jobs_record_event() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' OR
+       NEW.status IS DISTINCT FROM OLD.status OR
+       NEW.progress IS DISTINCT FROM OLD.progress THEN
+        INSERT INTO job_event (job_id, status, progress) VALUES (NEW.id, NEW.status, NEW.progress);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER jobs_record_event
+    AFTER INSERT OR UPDATE
+    ON jobs
+    FOR EACH ROW
+EXECUTE PROCEDURE jobs_record_event();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS image_notify ON image;
+DROP FUNCTION IF EXISTS image_notify();
+DROP TRIGGER IF EXISTS job_event_notify ON job_event;
+DROP FUNCTION IF EXISTS job_event_notify();
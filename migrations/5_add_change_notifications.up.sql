@@ -0,0 +1,45 @@
+-- Job events are announced on the job_events channel, the payload carries the
+-- ID of the job_event row so listeners can backfill anything they missed.
+CREATE OR REPLACE FUNCTION job_event_notify() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('job_events', json_build_object(
+            'id', NEW.id,
+            'jobId', NEW.job_id,
+            'status', NEW.status,
+            'progress', NEW.progress
+        )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER job_event_notify
+    AFTER INSERT
+    ON job_event
+    FOR EACH ROW
+EXECUTE PROCEDURE job_event_notify();
+
+CREATE OR REPLACE FUNCTION image_notify() RETURNS TRIGGER AS
+$$
+DECLARE
+    rec RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec = OLD;
+    ELSE
+        rec = NEW;
+    END IF;
+    PERFORM pg_notify('image_events', json_build_object(
+            'op', TG_OP,
+            'imageId', rec.id,
+            'projectId', rec.project_id
+        )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER image_notify
+    AFTER INSERT OR UPDATE OR DELETE
+    ON image
+    FOR EACH ROW
+EXECUTE PROCEDURE image_notify();
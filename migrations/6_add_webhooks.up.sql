@@ -0,0 +1,36 @@
+CREATE TABLE webhook
+(
+    id          uuid primary key                          default uuid_generate_v4(),
+    user_record uuid REFERENCES user_record (id) NOT NULL,
+    -- when null the webhook is called for every project of the user
+    project_id  uuid REFERENCES project (id) ON DELETE CASCADE,
+    url         TEXT                             NOT NULL,
+    secret      TEXT                             NOT NULL,
+    active      BOOLEAN                          NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMP                        NOT NULL,
+    updated_at  TIMESTAMP                        NOT NULL
+);
+
+CREATE INDEX idx_webhook_user_record on webhook (user_record);
+
+CREATE TABLE webhook_delivery
+(
+    id              uuid primary key                                   default uuid_generate_v4(),
+    webhook_id      uuid REFERENCES webhook (id) ON DELETE CASCADE NOT NULL,
+    job_id          uuid REFERENCES jobs (id) ON DELETE CASCADE    NOT NULL,
+    event           TEXT                                           NOT NULL,
+    -- the exact body sent, kept as text so the signature can be reproduced
+    payload         TEXT                                           NOT NULL,
+    status          TEXT                                           NOT NULL,
+    attempts        INTEGER                                        NOT NULL DEFAULT 0,
+    response_code   INTEGER,
+    error           TEXT,
+    next_attempt_at TIMESTAMP,
+    delivered_at    TIMESTAMP,
+    created_at      TIMESTAMP                                      NOT NULL,
+    updated_at      TIMESTAMP                                      NOT NULL,
+    UNIQUE (webhook_id, job_id, event)
+);
+
+CREATE INDEX idx_webhook_delivery_webhook_id on webhook_delivery (webhook_id, created_at);
+CREATE INDEX idx_webhook_delivery_pending on webhook_delivery (next_attempt_at) WHERE status = 'PENDING';
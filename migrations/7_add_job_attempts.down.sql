@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN error;
+ALTER TABLE jobs DROP COLUMN attempts;
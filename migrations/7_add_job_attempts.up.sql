@@ -0,0 +1,4 @@
+-- how many times the job has been put back in the queue after getting stuck
+ALTER TABLE jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+-- why the job failed, if it did
+ALTER TABLE jobs ADD COLUMN error TEXT;
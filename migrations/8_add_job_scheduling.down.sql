@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_status;
+ALTER TABLE user_record DROP COLUMN max_concurrent_jobs;
+ALTER TABLE jobs DROP COLUMN priority;
@@ -0,0 +1,6 @@
+-- jobs with a higher priority are scheduled first among the jobs of a user
+ALTER TABLE jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+-- overrides the configured number of jobs a user may have running at once
+ALTER TABLE user_record ADD COLUMN max_concurrent_jobs INTEGER;
+
+CREATE INDEX idx_jobs_status on jobs (status, priority DESC, created_at);
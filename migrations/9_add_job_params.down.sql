@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN params;
+ALTER TABLE image DROP COLUMN checksum;
@@ -0,0 +1,5 @@
+-- sha256 of the uploaded file, hex encoded
+ALTER TABLE image ADD COLUMN checksum TEXT;
+
+-- the keywords, images and options the job was started with
+ALTER TABLE jobs ADD COLUMN params JSONB;
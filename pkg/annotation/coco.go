@@ -0,0 +1,103 @@
+package annotation
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type cocoDataset struct {
+	Info        cocoInfo         `json:"info"`
+	Images      []cocoImage      `json:"images"`
+	Annotations []cocoAnnotation `json:"annotations"`
+	Categories  []cocoCategory   `json:"categories"`
+}
+
+type cocoInfo struct {
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	DateCreated string `json:"date_created"`
+}
+
+type cocoImage struct {
+	ID       int    `json:"id"`
+	FileName string `json:"file_name"`
+	CocoURL  string `json:"coco_url"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type cocoAnnotation struct {
+	ID           int        `json:"id"`
+	ImageID      int        `json:"image_id"`
+	CategoryID   int        `json:"category_id"`
+	Segmentation cocoRLE    `json:"segmentation"`
+	Area         int        `json:"area"`
+	BBox         [4]float64 `json:"bbox"`
+	IsCrowd      int        `json:"iscrowd"`
+}
+
+// cocoRLE is an uncompressed COCO run length encoding, counts are in column
+// major order starting with unset pixels.
+type cocoRLE struct {
+	Size   [2]int   `json:"size"`
+	Counts []uint64 `json:"counts"`
+}
+
+type cocoCategory struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Supercategory string `json:"supercategory"`
+}
+
+// WriteCOCO writes the dataset as COCO JSON, with RLE segmentations.
+func WriteCOCO(w io.Writer, d *Dataset) error {
+	res := cocoDataset{
+		Info: cocoInfo{
+			Description: d.Name,
+			Version:     "1.0",
+			DateCreated: d.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		},
+		Images:      make([]cocoImage, len(d.Images)),
+		Annotations: make([]cocoAnnotation, 0),
+		Categories:  make([]cocoCategory, len(d.Categories)),
+	}
+
+	for i, c := range d.Categories {
+		res.Categories[i] = cocoCategory{
+			ID:            c.ID,
+			Name:          c.Name,
+			Supercategory: c.Supercategory,
+		}
+	}
+
+	for i, img := range d.Images {
+		res.Images[i] = cocoImage{
+			ID:       img.ID,
+			FileName: img.FileName,
+			CocoURL:  img.URL,
+			Width:    img.Width,
+			Height:   img.Height,
+		}
+
+		for _, obj := range img.Objects {
+			res.Annotations = append(res.Annotations, cocoAnnotation{
+				ID:         len(res.Annotations) + 1,
+				ImageID:    img.ID,
+				CategoryID: obj.CategoryID,
+				Segmentation: cocoRLE{
+					Size:   [2]int{img.Height, img.Width},
+					Counts: obj.Runs,
+				},
+				Area: obj.Area,
+				BBox: [4]float64{
+					float64(obj.Bounds.Min.X),
+					float64(obj.Bounds.Min.Y),
+					float64(obj.Bounds.Dx()),
+					float64(obj.Bounds.Dy()),
+				},
+			})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(res)
+}
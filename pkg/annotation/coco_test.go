@@ -0,0 +1,126 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caquillo07/pyvinci-server/pkg/mask"
+)
+
+// newMask builds a mask from rows of 0s and 1s.
+func newMask(rows ...string) *mask.Mask {
+	m := mask.New(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			m.Set(x, y, c == '1')
+		}
+	}
+	return m
+}
+
+func writeCOCO(t *testing.T, d *Dataset) cocoDataset {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := WriteCOCO(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	var res cocoDataset
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestWriteCOCORLE(t *testing.T) {
+	d := &Dataset{
+		Name:       "cats",
+		CreatedAt:  time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		Categories: []Category{{ID: 1, Name: "cat", Supercategory: SupercategoryThing}},
+		Images: []Image{
+			{
+				ID: 1, FileName: "a.png", Width: 3, Height: 2,
+				Objects: []Object{NewObject(1, newMask(
+					"100",
+					"110",
+				), 3, 2)},
+			},
+			{
+				// masks smaller than the image are scaled up to it
+				ID: 2, FileName: "b.png", Width: 4, Height: 2,
+				Objects: []Object{NewObject(1, newMask("01"), 4, 2)},
+			},
+			{
+				ID: 3, FileName: "c.png", Width: 2, Height: 2,
+				Objects: []Object{NewObject(1, newMask("00", "00"), 2, 2)},
+			},
+		},
+	}
+
+	res := writeCOCO(t, d)
+	want := []cocoAnnotation{
+		{
+			ID: 1, ImageID: 1, CategoryID: 1,
+			// columns top to bottom: 11 01 00
+			Segmentation: cocoRLE{Size: [2]int{2, 3}, Counts: []uint64{0, 2, 1, 1, 2}},
+			Area:         3,
+			BBox:         [4]float64{0, 0, 2, 2},
+		},
+		{
+			ID: 2, ImageID: 2, CategoryID: 1,
+			Segmentation: cocoRLE{Size: [2]int{2, 4}, Counts: []uint64{4, 4}},
+			Area:         4,
+			BBox:         [4]float64{2, 0, 2, 2},
+		},
+		{
+			ID: 3, ImageID: 3, CategoryID: 1,
+			Segmentation: cocoRLE{Size: [2]int{2, 2}, Counts: []uint64{4}},
+		},
+	}
+	if !reflect.DeepEqual(res.Annotations, want) {
+		t.Errorf("annotations = %+v, want %+v", res.Annotations, want)
+	}
+	if res.Info.DateCreated != "2020-06-01T12:00:00Z" {
+		t.Errorf("date created = %s, want 2020-06-01T12:00:00Z", res.Info.DateCreated)
+	}
+}
+
+func TestWriteCOCOCategoryIDs(t *testing.T) {
+	// IDs come from the database and are kept as they are, whatever the
+	// order of the categories and images
+	categories := []Category{
+		{ID: 12, Name: "cat", Supercategory: SupercategoryThing},
+		{ID: 3, Name: "sky", Supercategory: SupercategoryStuff},
+	}
+	images := []Image{
+		{ID: 1, Width: 1, Height: 1, Objects: []Object{NewObject(12, newMask("1"), 1, 1)}},
+		{ID: 2, Width: 1, Height: 1, Objects: []Object{NewObject(3, newMask("1"), 1, 1)}},
+	}
+
+	first := writeCOCO(t, &Dataset{Categories: categories, Images: images})
+	second := writeCOCO(t, &Dataset{
+		Categories: []Category{categories[1], categories[0]},
+		Images:     []Image{images[1], images[0]},
+	})
+
+	for _, res := range []cocoDataset{first, second} {
+		names := map[int]string{}
+		for _, c := range res.Categories {
+			names[c.ID] = c.Name
+		}
+		want := map[int]string{12: "cat", 3: "sky"}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("categories = %v, want %v", names, want)
+		}
+
+		for _, a := range res.Annotations {
+			wantCategory := map[int]int{1: 12, 2: 3}[a.ImageID]
+			if a.CategoryID != wantCategory {
+				t.Errorf("annotation of image %d has category %d, want %d", a.ImageID, a.CategoryID, wantCategory)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package annotation exports the labels and masks of a project in standard
+// annotation formats, so they can be used as training data.
+package annotation
+
+import (
+	"image"
+	"time"
+
+	"github.com/caquillo07/pyvinci-server/pkg/mask"
+)
+
+// Supercategories of the exported categories.
+const (
+	SupercategoryThing = "thing"
+	SupercategoryStuff = "stuff"
+	SupercategoryOther = "other"
+)
+
+// Dataset is the set of annotated images of a project.
+type Dataset struct {
+	Name       string
+	CreatedAt  time.Time
+	Categories []Category
+	Images     []Image
+}
+
+// Category is a label, with an ID that is stable across exports.
+type Category struct {
+	ID            int
+	Name          string
+	Supercategory string
+}
+
+// Image is an annotated image. IDs are only unique within a dataset.
+type Image struct {
+	ID       int
+	FileName string
+	URL      string
+	Width    int
+	Height   int
+	Objects  []Object
+}
+
+// Object is a segmented region of an image. Only what the formats need is
+// kept of its mask, so a dataset holds no pixels.
+type Object struct {
+	CategoryID int
+
+	// Bounds is the smallest rectangle holding the object, empty when no
+	// pixel is set
+	Bounds image.Rectangle
+	Area   int
+
+	// Runs is the run length encoding of the mask in column major order,
+	// at the size of the image
+	Runs []uint64
+}
+
+// NewObject returns the object segmented by the mask on an image of the given
+// size. Masks of another size are scaled to the image without copying them.
+func NewObject(categoryID int, m *mask.Mask, width, height int) Object {
+	scaled := m.Scaled(width, height)
+	return Object{
+		CategoryID: categoryID,
+		Bounds:     scaled.Bounds(),
+		Area:       scaled.Area(),
+		Runs:       scaled.ColumnRuns(),
+	}
+}
+
+func (d *Dataset) category(id int) Category {
+	for _, c := range d.Categories {
+		if c.ID == id {
+			return c
+		}
+	}
+	return Category{ID: id}
+}
@@ -0,0 +1,114 @@
+package annotation
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"path"
+	"strings"
+)
+
+type vocAnnotation struct {
+	XMLName   xml.Name    `xml:"annotation"`
+	Folder    string      `xml:"folder"`
+	Filename  string      `xml:"filename"`
+	Source    vocSource   `xml:"source"`
+	Size      vocSize     `xml:"size"`
+	Segmented int         `xml:"segmented"`
+	Objects   []vocObject `xml:"object"`
+}
+
+type vocSource struct {
+	Database string `xml:"database"`
+	URL      string `xml:"url"`
+}
+
+type vocSize struct {
+	Width  int `xml:"width"`
+	Height int `xml:"height"`
+	Depth  int `xml:"depth"`
+}
+
+type vocObject struct {
+	Name      string    `xml:"name"`
+	Pose      string    `xml:"pose"`
+	Truncated int       `xml:"truncated"`
+	Difficult int       `xml:"difficult"`
+	BndBox    vocBndBox `xml:"bndbox"`
+}
+
+// vocBndBox uses 1 based, inclusive pixel coordinates.
+type vocBndBox struct {
+	XMin int `xml:"xmin"`
+	YMin int `xml:"ymin"`
+	XMax int `xml:"xmax"`
+	YMax int `xml:"ymax"`
+}
+
+// WriteVOC writes the dataset as a zip archive with a Pascal VOC XML file per
+// image under Annotations/. Only the labels with a mask have a bounding box,
+// so those are the only ones exported.
+func WriteVOC(w io.Writer, d *Dataset) error {
+	archive := zip.NewWriter(w)
+	folder := vocFolderName(d.Name)
+
+	for _, img := range d.Images {
+		a := vocAnnotation{
+			Folder:   folder,
+			Filename: img.FileName,
+			Source: vocSource{
+				Database: "pyvinci",
+				URL:      img.URL,
+			},
+			Size: vocSize{
+				Width:  img.Width,
+				Height: img.Height,
+				Depth:  3,
+			},
+			Objects: make([]vocObject, 0, len(img.Objects)),
+		}
+		if len(img.Objects) > 0 {
+			a.Segmented = 1
+		}
+
+		for _, obj := range img.Objects {
+			bounds := obj.Bounds
+			if bounds.Empty() {
+				continue
+			}
+			a.Objects = append(a.Objects, vocObject{
+				Name: d.category(obj.CategoryID).Name,
+				Pose: "Unspecified",
+				BndBox: vocBndBox{
+					XMin: bounds.Min.X + 1,
+					YMin: bounds.Min.Y + 1,
+					XMax: bounds.Max.X,
+					YMax: bounds.Max.Y,
+				},
+			})
+		}
+
+		name := strings.TrimSuffix(img.FileName, path.Ext(img.FileName)) + ".xml"
+		f, err := archive.Create(path.Join("Annotations", name))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(f)
+		enc.Indent("", "  ")
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func vocFolderName(name string) string {
+	if name == "" {
+		return "pyvinci"
+	}
+	return name
+}
@@ -0,0 +1,126 @@
+// Package apierror defines the errors returned by the API. Every error
+// carries the HTTP status to respond with and a stable, machine readable
+// code clients can rely on, unlike the message which is meant for humans
+// and may change.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// Stable error codes. Resource specific not found codes are built by
+// NotFound, e.g. project_not_found.
+const (
+	CodeInternal               = "internal_error"
+	CodeInvalidRequest         = "invalid_request"
+	CodeValidationFailed       = "validation_failed"
+	CodeInvalidJSON            = "invalid_json"
+	CodeUnsupportedContentType = "unsupported_content_type"
+	CodeUnauthorized           = "unauthorized"
+	CodeForbidden              = "forbidden"
+	CodeNotFound               = "not_found"
+	CodeAlreadyExists          = "already_exists"
+	CodeUsernameTaken          = "username_taken"
+	CodeReferenceNotFound      = "reference_not_found"
+	CodeConflict               = "conflict"
+	CodePreconditionFailed     = "precondition_failed"
+	CodeUnavailable            = "unavailable"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeJobAlreadyExists       = "job_already_exists"
+	CodeIdempotencyKeyReused   = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse    = "idempotency_key_in_use"
+	CodeAlreadyMember          = "already_member"
+	CodeLastOwner              = "last_owner"
+	CodeSharePasswordRequired  = "share_password_required"
+	CodeInvalidSharePassword   = "invalid_share_password"
+	CodeShareLocked            = "share_locked"
+)
+
+// FieldError describes why a single field of a request is not valid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error meant to be shown to API clients.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+
+	// cause is the underlying error, it is logged but never shown
+	cause error
+}
+
+func New(status int, code, msg string) *Error {
+	return &Error{Status: status, Code: code, Message: msg}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Wrap returns a copy of the error caused by err.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+// Validation returns an error for a request that is not valid as a whole.
+func Validation(msg string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, msg)
+}
+
+// Fields returns an error listing every invalid field of a request.
+func Fields(fields ...FieldError) *Error {
+	e := New(http.StatusBadRequest, CodeValidationFailed, "request has invalid fields")
+	e.Fields = fields
+	return e
+}
+
+// NotFound returns the error for a missing resource, its code is the
+// resource name followed by _not_found.
+func NotFound(resource string) *Error {
+	code := strings.ReplaceAll(resource, " ", "_") + "_not_found"
+	return New(http.StatusNotFound, code, resource+" not found")
+}
+
+// Internal hides err behind a generic message.
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "internal error").Wrap(err)
+}
+
+// From converts any error into an API error. Errors from the database are
+// mapped by their type or SQLSTATE code, anything unknown becomes an
+// internal error.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	if gorm.IsRecordNotFoundError(err) {
+		return New(http.StatusNotFound, CodeNotFound, "record does not exist").Wrap(err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return fromPQ(pqErr)
+	}
+	return Internal(err)
+}
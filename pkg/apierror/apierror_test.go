@@ -0,0 +1,88 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+func TestFrom(t *testing.T) {
+	known := New(http.StatusForbidden, CodeForbidden, "not yours")
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"api error", known, http.StatusForbidden, CodeForbidden},
+		{"wrapped api error", fmt.Errorf("loading: %w", known), http.StatusForbidden, CodeForbidden},
+		{"record not found", gorm.ErrRecordNotFound, http.StatusNotFound, CodeNotFound},
+		{"unknown error", errors.New("boom"), http.StatusInternalServerError, CodeInternal},
+
+		{"unique violation", &pq.Error{Code: "23505"}, http.StatusConflict, CodeAlreadyExists},
+		{"foreign key violation", &pq.Error{Code: "23503"}, http.StatusConflict, CodeReferenceNotFound},
+		{"not null violation", &pq.Error{Code: "23502"}, http.StatusBadRequest, CodeInvalidRequest},
+		{"check violation", &pq.Error{Code: "23514"}, http.StatusBadRequest, CodeInvalidRequest},
+		{"value too long", &pq.Error{Code: "22001"}, http.StatusBadRequest, CodeInvalidRequest},
+		{"invalid text", &pq.Error{Code: "22P02"}, http.StatusBadRequest, CodeInvalidRequest},
+		{"invalid datetime", &pq.Error{Code: "22007"}, http.StatusBadRequest, CodeInvalidRequest},
+		{"number out of range", &pq.Error{Code: "22003"}, http.StatusBadRequest, CodeInvalidRequest},
+		{"serialization failure", &pq.Error{Code: "40001"}, http.StatusConflict, CodeConflict},
+		{"deadlock", &pq.Error{Code: "40P01"}, http.StatusConflict, CodeConflict},
+		{"lock not available", &pq.Error{Code: "55P03"}, http.StatusConflict, CodeConflict},
+		{"too many connections", &pq.Error{Code: "53300"}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"starting up", &pq.Error{Code: "57P03"}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"shutting down", &pq.Error{Code: "57P01"}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"unmapped code", &pq.Error{Code: "42601"}, http.StatusInternalServerError, CodeInternal},
+		{"wrapped pq error", fmt.Errorf("saving: %w", &pq.Error{Code: "23505"}), http.StatusConflict, CodeAlreadyExists},
+
+		// well known constraints win over the SQLSTATE code
+		{
+			"username taken",
+			&pq.Error{Code: "23505", Constraint: "user_record_username_key"},
+			http.StatusConflict, CodeUsernameTaken,
+		},
+		{
+			"already member",
+			&pq.Error{Code: "23505", Constraint: "project_member_pkey"},
+			http.StatusConflict, CodeAlreadyMember,
+		},
+		{
+			"unknown constraint",
+			&pq.Error{Code: "23505", Constraint: "project_name_key"},
+			http.StatusConflict, CodeAlreadyExists,
+		},
+	}
+	for _, tt := range tests {
+		got := From(tt.err)
+		if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+			t.Errorf("%s: From() = %d %s, want %d %s", tt.name, got.Status, got.Code, tt.wantStatus, tt.wantCode)
+		}
+		if got == known {
+			continue
+		}
+		// the cause is kept for the logs, which may be the database error
+		// found in the chain rather than err itself
+		if cause := got.Unwrap(); cause == nil || !errors.Is(tt.err, cause) {
+			t.Errorf("%s: From() is caused by %v, want a cause from %v", tt.name, cause, tt.err)
+		}
+	}
+}
+
+func TestFromConstraintKeepsCause(t *testing.T) {
+	first := From(&pq.Error{Code: "23505", Constraint: "user_record_username_key", Message: "first"})
+	second := From(&pq.Error{Code: "23505", Constraint: "user_record_username_key", Message: "second"})
+
+	// the shared constraint errors are copied, never modified
+	if first.Unwrap() == second.Unwrap() {
+		t.Error("constraint errors share their cause")
+	}
+	if constraintCodes["user_record_username_key"].Unwrap() != nil {
+		t.Error("constraint error was modified")
+	}
+}
@@ -0,0 +1,38 @@
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// constraintCodes gives a more precise code to the violations of well known
+// constraints.
+var constraintCodes = map[string]*Error{
+	"user_record_username_key": New(http.StatusConflict, CodeUsernameTaken, "username is already taken"),
+	"project_member_pkey":      New(http.StatusConflict, CodeAlreadyMember, "user is already a member of the project"),
+}
+
+// fromPQ maps Postgres errors by their SQLSTATE code, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func fromPQ(err *pq.Error) *Error {
+	if e, ok := constraintCodes[err.Constraint]; ok {
+		return e.Wrap(err)
+	}
+
+	switch err.Code.Name() {
+	case "unique_violation":
+		return New(http.StatusConflict, CodeAlreadyExists, "record already exists").Wrap(err)
+	case "foreign_key_violation":
+		return New(http.StatusConflict, CodeReferenceNotFound, "a referenced record does not exist").Wrap(err)
+	case "not_null_violation", "check_violation", "string_data_right_truncation":
+		return New(http.StatusBadRequest, CodeInvalidRequest, "request has an invalid value").Wrap(err)
+	case "invalid_text_representation", "invalid_datetime_format", "numeric_value_out_of_range":
+		return New(http.StatusBadRequest, CodeInvalidRequest, "request has a malformed value").Wrap(err)
+	case "serialization_failure", "deadlock_detected", "lock_not_available":
+		return New(http.StatusConflict, CodeConflict, "request conflicted with another one, retry it").Wrap(err)
+	case "too_many_connections", "cannot_connect_now", "admin_shutdown":
+		return New(http.StatusServiceUnavailable, CodeUnavailable, "service is temporarily unavailable").Wrap(err)
+	}
+	return Internal(err)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrNotLoggedIn is returned by the calls that need a user when the client
+// has no session.
+var ErrNotLoggedIn = errors.New("client: not logged in")
+
+type User struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Register creates a new user, it does not log in.
+func (c *Client) Register(ctx context.Context, username, password string) (*User, error) {
+	var res User
+	err := c.doJSON(ctx, &request{
+		method: http.MethodPost,
+		path:   "/auth/register",
+		body:   jsonBody(credentials{Username: username, Password: password}),
+		public: true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Login starts a session for the user. The password is kept in memory so the
+// token can be renewed once it expires.
+func (c *Client) Login(ctx context.Context, username, password string) (*Session, error) {
+	s, err := c.login(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.session = s
+	c.password = password
+	c.mu.Unlock()
+
+	res := *s
+	return &res, nil
+}
+
+func (c *Client) login(ctx context.Context, username, password string) (*Session, error) {
+	var res struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Token    string `json:"token"`
+		ExpireAt int64  `json:"expireAt"`
+	}
+	err := c.doJSON(ctx, &request{
+		method: http.MethodPost,
+		path:   "/auth/login",
+		body:   jsonBody(credentials{Username: username, Password: password}),
+		public: true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		UserID:   res.ID,
+		Username: res.Username,
+		Token:    res.Token,
+		ExpireAt: time.Unix(res.ExpireAt, 0),
+	}, nil
+}
+
+func (c *Client) canRefresh() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session != nil && c.password != ""
+}
+
+// refresh logs in again when the token is about to expire, or right away
+// when forced. Sessions set without a password are left as they are and
+// the server decides whether they are still good.
+func (c *Client) refresh(ctx context.Context, force bool) error {
+	c.mu.Lock()
+	if c.session == nil {
+		c.mu.Unlock()
+		return ErrNotLoggedIn
+	}
+	if c.password == "" || (!force && !c.session.expiresSoon()) {
+		c.mu.Unlock()
+		return nil
+	}
+	username, password := c.session.Username, c.password
+	c.mu.Unlock()
+
+	s, err := c.login(ctx, username, password)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.session = s
+	c.mu.Unlock()
+	return nil
+}
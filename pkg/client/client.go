@@ -0,0 +1,205 @@
+// Package client is a Go client of the pyvinci REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokens expiring within this window are renewed before being used
+const refreshWindow = time.Minute
+
+// Session is the token of a logged in user. It can be stored and given back
+// to SetSession to skip logging in again.
+type Session struct {
+	UserID   string    `json:"userId"`
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	ExpireAt time.Time `json:"expireAt"`
+}
+
+func (s *Session) expiresSoon() bool {
+	return !s.ExpireAt.IsZero() && time.Until(s.ExpireAt) < refreshWindow
+}
+
+// Client calls the API under the given base URL, such as
+// http://localhost:3000/api/v1. It is safe for concurrent use.
+type Client struct {
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+
+	baseURL string
+
+	mu       sync.Mutex
+	session  *Session
+	password string
+}
+
+func New(baseURL string) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Session returns the current session, nil when not logged in.
+func (c *Client) Session() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return nil
+	}
+	s := *c.session
+	return &s
+}
+
+// SetSession authenticates the client with a session obtained earlier. The
+// client can not log in again once it expires.
+func (c *Client) SetSession(s *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = s
+	c.password = ""
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// userPath prefixes path with the resources of the logged in user.
+func (c *Client) userPath(format string, args ...interface{}) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return "", ErrNotLoggedIn
+	}
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = url.PathEscape(s)
+		}
+	}
+	return "/users/" + url.PathEscape(c.session.UserID) + fmt.Sprintf(format, args...), nil
+}
+
+// request describes a call to the API. Its body is built by a function so
+// the request can be sent again after renewing the token.
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	header  http.Header
+	body    func() (io.Reader, string, error)
+	public  bool
+	noRetry bool
+}
+
+// idempotencyHeader returns the header sending key, nil when it is empty.
+func idempotencyHeader(key string) http.Header {
+	if key == "" {
+		return nil
+	}
+	return http.Header{"Idempotency-Key": []string{key}}
+}
+
+func jsonBody(v interface{}) func() (io.Reader, string, error) {
+	return func() (io.Reader, string, error) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(b), "application/json", nil
+	}
+}
+
+// do sends the request and returns the response when successful. Failed
+// responses are returned as an *Error. A request rejected because of an
+// expired token is sent again after logging in, as long as the client knows
+// the password.
+func (c *Client) do(ctx context.Context, r *request) (*http.Response, error) {
+	if !r.public {
+		if err := c.refresh(ctx, false); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized && !r.public && !r.noRetry && c.canRefresh() {
+		res.Body.Close()
+		if err := c.refresh(ctx, true); err != nil {
+			return nil, err
+		}
+		if res, err = c.send(ctx, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		return nil, decodeError(res)
+	}
+	return res, nil
+}
+
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	var body io.Reader
+	var contentType string
+	if r.body != nil {
+		var err error
+		if body, contentType, err = r.body(); err != nil {
+			return nil, err
+		}
+	}
+
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if !r.public {
+		c.mu.Lock()
+		if c.session != nil {
+			req.Header.Set("Authorization", "Bearer "+c.session.Token)
+		}
+		c.mu.Unlock()
+	}
+	return c.httpClient().Do(req)
+}
+
+// doJSON sends the request and decodes the JSON response into v, unless v
+// is nil.
+func (c *Client) doJSON(ctx context.Context, r *request, v interface{}) error {
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response of %s %s: %w", r.method, r.path, err)
+	}
+	return nil
+}
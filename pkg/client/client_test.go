@@ -0,0 +1,507 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofrs/uuid"
+	gomigrate "github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/conf"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/server"
+)
+
+const testTokenSecret = "client-test-secret"
+
+// testServer is a server.Server listening on a local port, behind a proxy
+// counting the logins it receives.
+type testServer struct {
+	*httptest.Server
+	srv    *server.Server
+	logins int32
+}
+
+// newTestServer starts a server using db, which may be nil for tests that
+// never reach the database. The background tasks of the server are not
+// started.
+func newTestServer(t *testing.T, db *gorm.DB) *testServer {
+	config := &conf.Config{}
+	config.Auth.Enabled = true
+	config.Auth.TokenSecret = testTokenSecret
+	config.Database.ConnectionString = os.Getenv("PYVINCI_TEST_DATABASE")
+	ts := &testServer{srv: server.NewServer(config, db)}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- ts.srv.Listener(ln)
+	}()
+	t.Cleanup(func() {
+		if err := ts.srv.Shutdown(); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+	})
+
+	// responses are passed on as they come, so event streams are streamed
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: ln.Addr().String()})
+	proxy.FlushInterval = -1
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/auth/login") {
+			atomic.AddInt32(&ts.logins, 1)
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *testServer) client() *Client {
+	return New(ts.URL + "/api/v1")
+}
+
+func (ts *testServer) loginCount() int {
+	return int(atomic.LoadInt32(&ts.logins))
+}
+
+// testDB connects to the database in PYVINCI_TEST_DATABASE and migrates it,
+// skipping the test when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("PYVINCI_TEST_DATABASE")
+	if dsn == "" {
+		t.Skip("PYVINCI_TEST_DATABASE is not set")
+	}
+
+	db, err := database.Open(database.Config{Driver: "postgres", ConnectionString: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := postgres.WithInstance(db.DB(), &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := gomigrate.NewWithDatabaseInstance("file://../../migrations", "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil && err != gomigrate.ErrNoChange {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// expiredToken is a token of the user signed by the test server that
+// expired an hour ago.
+func expiredToken(t *testing.T, userID, username string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": username,
+		"userId":   userID,
+		"exp":      time.Now().Add(-time.Hour).Unix(),
+	})
+	s, err := token.SignedString([]byte(testTokenSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newUsername() string {
+	return "client-test-" + uuid.Must(uuid.NewV4()).String()[:8]
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	ts := newTestServer(t, testDB(t))
+	c := ts.client()
+	ctx := context.Background()
+	username := newUsername()
+
+	user, err := c.Register(ctx, username, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != username || user.ID == "" {
+		t.Errorf("Register() = %+v, want a user named %s", user, username)
+	}
+	if c.Session() != nil {
+		t.Error("Register() logged in")
+	}
+
+	_, err = c.Register(ctx, username, "password123")
+	if !HasCode(err, apierror.CodeUsernameTaken) {
+		t.Errorf("Register() of a taken username error = %v, want %s", err, apierror.CodeUsernameTaken)
+	}
+
+	_, err = c.Login(ctx, username, "wrong password")
+	if !HasCode(err, apierror.CodeInvalidCredentials) {
+		t.Errorf("Login() with a wrong password error = %v, want %s", err, apierror.CodeInvalidCredentials)
+	}
+
+	s, err := c.Login(ctx, username, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UserID != user.ID || s.Token == "" || !s.ExpireAt.After(time.Now()) {
+		t.Errorf("Login() = %+v, want a session of user %s", s, user.ID)
+	}
+
+	project, err := c.CreateProject(ctx, "cats", []string{"cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetProject(ctx, project.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "cats" {
+		t.Errorf("GetProject().Name = %q, want %q", got.Name, "cats")
+	}
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	ts := newTestServer(t, testDB(t))
+	c := ts.client()
+	ctx := context.Background()
+	username := newUsername()
+
+	if _, err := c.Register(ctx, username, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Login(ctx, username, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server rejects the token although the client thinks it is good
+	expired := expiredToken(t, s.UserID, username)
+	c.mu.Lock()
+	c.session.Token = expired
+	c.mu.Unlock()
+
+	if _, err := c.ListProjects(ctx, ListProjectsOptions{}); err != nil {
+		t.Fatalf("ListProjects() error = %v, want the token renewed", err)
+	}
+	if n := ts.loginCount(); n != 2 {
+		t.Errorf("server got %d logins, want 2", n)
+	}
+	if c.Session().Token == expired {
+		t.Error("session still has the expired token")
+	}
+
+	// sessions set without a password can not be renewed
+	c.SetSession(&Session{UserID: s.UserID, Username: username, Token: expired})
+	_, err = c.ListProjects(ctx, ListProjectsOptions{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ListProjects() error = %v, want a 401", err)
+	}
+	if n := ts.loginCount(); n != 2 {
+		t.Errorf("server got %d logins, want 2", n)
+	}
+}
+
+func TestUploadImagesStreamsReaders(t *testing.T) {
+	ts := newTestServer(t, testDB(t))
+	c := ts.client()
+	ctx := context.Background()
+	username := newUsername()
+
+	if _, err := c.Register(ctx, username, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, username, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	project, err := c.CreateProject(ctx, "uploads", []string{"cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one file more than the server takes, so it reads the whole stream and
+	// rejects it without storing anything
+	uploads := make([]Upload, 51)
+	for i := range uploads {
+		uploads[i] = Upload{
+			Name:   fmt.Sprintf("image-%d.png", i),
+			Reader: strings.NewReader("not really a png"),
+		}
+	}
+	_, err = c.UploadImages(ctx, project.ID, uploads, UploadImagesOptions{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("UploadImages() error = %v, want an *Error", err)
+	}
+	if apiErr.Code != apierror.CodeValidationFailed || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "images" {
+		t.Errorf("UploadImages() error = %v, want too many images", apiErr)
+	}
+}
+
+func TestWatchJob(t *testing.T) {
+	db := testDB(t)
+	ts := newTestServer(t, db)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	// job events reach the stream through the database listener
+	ts.srv.Start(ctx)
+
+	c := ts.client()
+	username := newUsername()
+	if _, err := c.Register(ctx, username, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, username, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	project, err := c.CreateProject(ctx, "watched", []string{"cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := model.CreateNewJob(db, uuid.FromStringOrNil(project.ID), 0, model.JobParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the job only moves on once its first event was received, so the rest
+	// has to be streamed
+	advance := func() {
+		for _, changes := range []map[string]interface{}{
+			{"status": model.JobStatusProcessing, "progress": 50},
+			{"status": model.JobStatusFinished, "progress": 100},
+		} {
+			if err := db.Table("jobs").Where("id = ?", job.ID).Updates(changes).Error; err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}
+
+	var got []*JobEvent
+	err = c.WatchJob(ctx, project.ID, job.ID.String(), func(e *JobEvent) error {
+		got = append(got, e)
+		if len(got) == 1 {
+			go advance()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WatchJob() error = %v", err)
+	}
+
+	if len(got) < 3 || got[0].Status != model.JobStatusQueued {
+		t.Fatalf("WatchJob() events = %+v, want the job queued, processing and finished", got)
+	}
+	last := got[len(got)-1]
+	if last.Status != model.JobStatusFinished || last.Progress == nil || *last.Progress != 100 {
+		t.Errorf("last event = %+v, want the job finished", last)
+	}
+	processing := false
+	for i, e := range got {
+		if e.JobID != job.ID.String() || e.ID == "" {
+			t.Errorf("event %d = %+v, want an event of job %s with an ID", i, e, job.ID)
+		}
+		if e.Status == model.JobStatusProcessing && e.Progress != nil && *e.Progress == 50 {
+			processing = true
+		}
+	}
+	if !processing {
+		t.Errorf("WatchJob() events = %+v, want the job processing at 50%%", got)
+	}
+
+	// resuming after the final event finds the stream done, instead of
+	// waiting for more
+	path, err := c.userPath("/projects/%s/jobs/%s/events", project.ID, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := c.watchJob(ctx, path, &jobWatch{lastEventID: last.ID}, func(e *JobEvent) error {
+		t.Errorf("resumed stream sent %+v, want no events", e)
+		return nil
+	})
+	if err != nil || !done {
+		t.Errorf("watchJob() after the final event = %v, %v, want done", done, err)
+	}
+}
+
+func TestUploadImagesNoRetry(t *testing.T) {
+	ts := newTestServer(t, nil)
+	c := ts.client()
+	userID := uuid.Must(uuid.NewV4()).String()
+
+	// a client that could log in again, but must not for a streamed body
+	c.session = &Session{UserID: userID, Username: "someone", Token: expiredToken(t, userID, "someone")}
+	c.password = "password123"
+
+	read := false
+	_, err := c.UploadImages(context.Background(), uuid.Must(uuid.NewV4()).String(), []Upload{{
+		Name:   "cat.png",
+		Reader: readerFunc(func(p []byte) (int, error) { read = true; return 0, io.EOF }),
+	}}, UploadImagesOptions{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("UploadImages() error = %v, want a 401", err)
+	}
+	if !read {
+		t.Error("upload was not read")
+	}
+	if n := ts.loginCount(); n != 0 {
+		t.Errorf("server got %d logins, want none", n)
+	}
+}
+
+func TestUploadImagesReaderError(t *testing.T) {
+	ts := newTestServer(t, nil)
+	c := ts.client()
+	userID := uuid.Must(uuid.NewV4()).String()
+	c.SetSession(&Session{UserID: userID, Username: "someone", Token: expiredToken(t, userID, "someone")})
+
+	errBroken := errors.New("broken disk")
+	_, err := c.UploadImages(context.Background(), uuid.Must(uuid.NewV4()).String(), []Upload{{
+		Name:   "cat.png",
+		Reader: readerFunc(func(p []byte) (int, error) { return 0, errBroken }),
+	}}, UploadImagesOptions{})
+	if !errors.Is(err, errBroken) {
+		t.Errorf("UploadImages() error = %v, want %v", err, errBroken)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ctx := context.Background()
+
+	_, err := ts.client().Register(ctx, "a!", "short")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Register() error = %v, want an *Error", err)
+	}
+	want := []FieldError{
+		{Field: "username", Message: "must be between 3 and 50 characters"},
+		{Field: "password", Message: "must be between 8 and 72 characters"},
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != apierror.CodeValidationFailed {
+		t.Errorf("Register() error = %v, want a 400 %s", apiErr, apierror.CodeValidationFailed)
+	}
+	if fmt.Sprint(apiErr.Fields) != fmt.Sprint(want) {
+		t.Errorf("Register() error fields = %v, want %v", apiErr.Fields, want)
+	}
+	if apiErr.RequestID == "" {
+		t.Error("Register() error has no request id")
+	}
+	if apiErr.Message == "" {
+		t.Error("Register() error has no message")
+	}
+
+	// responses not coming from the API, such as unknown routes, keep their
+	// status and body
+	_, err = New(ts.URL).Register(ctx, "someone", "password123")
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Register() error = %v, want an *Error", err)
+	}
+	if !IsNotFound(err) || apiErr.Code != "" || apiErr.Message == "" {
+		t.Errorf("Register() at an unknown route error = %+v, want a 404 without code", apiErr)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	// the requests fail validation if they ever get through, so the server
+	// needs no database
+	ts := newTestServer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// cancel once the request reached the server, then let it through. The
+	// server only notices the client going away after reading the body.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		cancel()
+		<-r.Context().Done()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		ts.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+
+	_, err := New(slow.URL+"/api/v1").Register(ctx, "a!", "short")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Register() error = %v, want %v", err, context.Canceled)
+	}
+
+	_, err = ts.client().Register(ctx, "a!", "short")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Register() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.Copy(ioutil.Discard, r.Body); err != nil {
+			t.Error(err)
+		}
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL)
+	c.SetSession(&Session{UserID: uuid.Must(uuid.NewV4()).String(), Token: "token"})
+	ctx := context.Background()
+	projectID := uuid.Must(uuid.NewV4()).String()
+	uploads := func() []Upload {
+		return []Upload{{Name: "cat.png", Reader: strings.NewReader("cat")}}
+	}
+
+	if _, err := c.UploadImages(ctx, projectID, uploads(), UploadImagesOptions{IdempotencyKey: "upload-key"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadImages(ctx, projectID, uploads(), UploadImagesOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StartJob(ctx, projectID, StartJobOptions{IdempotencyKey: "job-key"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StartJob(ctx, projectID, StartJobOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"upload-key", "", "job-key", ""}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("Idempotency-Key headers = %q, want %q", keys, want)
+	}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
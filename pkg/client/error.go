@@ -0,0 +1,72 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// most of an error body worth reading when it is not the API's JSON
+const maxErrorBody = 4 << 10
+
+// Error is a failed response of the API. Code is one of the stable codes of
+// the apierror package, such as "project_not_found".
+type Error struct {
+	StatusCode int          `json:"status"`
+	Code       string       `json:"code"`
+	Message    string       `json:"error"`
+	Fields     []FieldError `json:"fields,omitempty"`
+	RequestID  string       `json:"requestId,omitempty"`
+}
+
+// FieldError is the problem with a single field of a rejected request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s: %s", e.StatusCode, e.Code, e.Message)
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "; %s %s", f.Field, f.Message)
+	}
+	return b.String()
+}
+
+func decodeError(res *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	if err != nil {
+		return err
+	}
+
+	e := &Error{}
+	if json.Unmarshal(body, e) != nil || e.Code == "" {
+		// not an API error, most likely a proxy in the way
+		e = &Error{Message: strings.TrimSpace(string(body))}
+		if e.Message == "" {
+			e.Message = http.StatusText(res.StatusCode)
+		}
+	}
+	e.StatusCode = res.StatusCode
+	if e.RequestID == "" {
+		e.RequestID = res.Header.Get("X-Request-ID")
+	}
+	return e
+}
+
+// HasCode reports whether err is an API error with the given code.
+func HasCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// IsNotFound reports whether err is an API error about a missing resource.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Image struct {
+	ID        string       `json:"id"`
+	URL       string       `json:"url"`
+	Checksum  string       `json:"checksum,omitempty"`
+	ProjectID string       `json:"projectId"`
+	Labels    *ImageLabels `json:"labels"`
+
+	// OriginalLabels are the labels found by the model, only set when the
+	// user corrected them
+	OriginalLabels *ImageLabels `json:"originalLabels,omitempty"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+}
+
+type ImageLabels struct {
+	Things []string           `json:"things"`
+	Stuff  []string           `json:"stuff"`
+	Masks  []string           `json:"masks"`
+	Scores map[string]float64 `json:"scores,omitempty"`
+}
+
+// ImagePage is a page of images, NextCursor is empty on the last one.
+type ImagePage struct {
+	Images     []*Image `json:"images"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// Upload is a file to upload, Name is the file name sent to the server.
+// ContentType is guessed from the extension of Name when empty.
+type Upload struct {
+	Name        string
+	ContentType string
+	Reader      io.Reader
+}
+
+type UploadImagesOptions struct {
+	// IdempotencyKey makes the upload safe to send again, the server
+	// replays its first response to uploads with the same key and files
+	// instead of storing the images twice
+	IdempotencyKey string
+}
+
+// UploadImages uploads the given files to the project in a single request.
+// The files are streamed, so the request can not be sent again and a token
+// expiring halfway is not renewed.
+func (c *Client) UploadImages(ctx context.Context, projectID string, uploads []Upload, opts UploadImagesOptions) ([]*Image, error) {
+	path, err := c.userPath("/projects/%s/images", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := func() (io.Reader, string, error) {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		go func() {
+			pw.CloseWithError(writeUploads(mw, uploads))
+		}()
+		return pr, mw.FormDataContentType(), nil
+	}
+
+	var res struct {
+		Images []*Image `json:"images"`
+	}
+	err = c.doJSON(ctx, &request{
+		method:  http.MethodPost,
+		path:    path,
+		header:  idempotencyHeader(opts.IdempotencyKey),
+		body:    body,
+		noRetry: true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Images, nil
+}
+
+// quoteEscaper escapes file names the way multipart.Writer.CreateFormFile does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeUploads(mw *multipart.Writer, uploads []Upload) error {
+	for _, u := range uploads {
+		contentType := u.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(u.Name))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		// the server stores the images with the content type of their part
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(
+			`form-data; name="images"; filename="%s"`,
+			quoteEscaper.Replace(u.Name),
+		))
+		header.Set("Content-Type", contentType)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, u.Reader); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+type ListImagesOptions struct {
+	PageOptions
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func (c *Client) ListImages(ctx context.Context, projectID string, opts ListImagesOptions) (*ImagePage, error) {
+	path, err := c.userPath("/projects/%s/images", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := opts.values()
+	setTimeQuery(q, "created_after", opts.CreatedAfter)
+	setTimeQuery(q, "created_before", opts.CreatedBefore)
+
+	var res ImagePage
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path, query: q}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SearchImages finds the images of every project of the user carrying all
+// the labels, or any of them when matchAny is set.
+func (c *Client) SearchImages(ctx context.Context, labels []string, matchAny bool, opts PageOptions) (*ImagePage, error) {
+	path, err := c.userPath("/images")
+	if err != nil {
+		return nil, err
+	}
+
+	q := opts.values()
+	q["label"] = labels
+	if matchAny {
+		q.Set("match", "any")
+	}
+
+	var res ImagePage
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path, query: q}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) GetImage(ctx context.Context, projectID, imageID string) (*Image, error) {
+	path, err := c.userPath("/projects/%s/images/%s", projectID, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Image *Image `json:"image"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path}, &res); err != nil {
+		return nil, err
+	}
+	return res.Image, nil
+}
+
+func (c *Client) DeleteImage(ctx context.Context, projectID, imageID string) error {
+	path, err := c.userPath("/projects/%s/images/%s", projectID, imageID)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: path}, nil)
+}
@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Job statuses.
+const (
+	JobStatusQueued        = "QUEUED"
+	JobStatusPendingLabels = "PENDING_LABELS"
+	JobStatusProcessing    = "PROCESSING"
+	JobStatusFinished      = "FINISHED"
+	JobStatusFailed        = "FAILED"
+)
+
+// how long to wait before reconnecting to a dropped event stream, unless
+// the server says otherwise
+const defaultRetryInterval = 3 * time.Second
+
+type Job struct {
+	ID             string     `json:"id"`
+	ProjectID      string     `json:"projectId"`
+	Status         string     `json:"status"`
+	Progress       *int       `json:"progress,omitempty"`
+	Priority       int        `json:"priority"`
+	Params         *JobParams `json:"params"`
+	QueuePosition  int        `json:"queuePosition,omitempty"`
+	Attempts       int        `json:"attempts"`
+	Error          string     `json:"error,omitempty"`
+	ResultImageURL string     `json:"resultImageUrl,omitempty"`
+	QueuedAt       time.Time  `json:"queuedAt"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+}
+
+type JobParams struct {
+	Keywords []string               `json:"keywords"`
+	Images   []JobImage             `json:"images"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type JobImage struct {
+	ID       string `json:"id"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// JobEvent is a status or progress change of a job.
+type JobEvent struct {
+	ID        string    `json:"-"`
+	JobID     string    `json:"jobId"`
+	Status    string    `json:"status"`
+	Progress  *int      `json:"progress,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsFinal reports whether the job is done, no events follow a final one.
+func (e *JobEvent) IsFinal() bool {
+	return e.Status == JobStatusFinished || e.Status == JobStatusFailed
+}
+
+// JobResult is the output of a finished job.
+type JobResult struct {
+	Job    *Job     `json:"job"`
+	Labels []string `json:"labels"`
+	Images []*Image `json:"images"`
+}
+
+type StartJobOptions struct {
+	// Priority of the job in the queue, higher goes first
+	Priority int `json:"priority"`
+
+	// Options are passed as is to the model
+	Options map[string]interface{} `json:"options,omitempty"`
+
+	// IdempotencyKey makes the request safe to send again, the server
+	// replays its first response instead of starting another job
+	IdempotencyKey string `json:"-"`
+}
+
+// StartedJob is the job queued by StartJob.
+type StartedJob struct {
+	JobID         string `json:"jobId"`
+	Status        string `json:"status"`
+	QueuePosition int    `json:"queuePosition,omitempty"`
+}
+
+func (c *Client) StartJob(ctx context.Context, projectID string, opts StartJobOptions) (*StartedJob, error) {
+	path, err := c.userPath("/projects/%s/job", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res StartedJob
+	err = c.doJSON(ctx, &request{
+		method: http.MethodPost,
+		path:   path,
+		header: idempotencyHeader(opts.IdempotencyKey),
+		body:   jsonBody(opts),
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) GetJob(ctx context.Context, projectID, jobID string) (*Job, error) {
+	path, err := c.userPath("/projects/%s/jobs/%s", projectID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Job *Job `json:"job"`
+	}
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path}, &res); err != nil {
+		return nil, err
+	}
+	return res.Job, nil
+}
+
+func (c *Client) GetJobResult(ctx context.Context, projectID, jobID string) (*JobResult, error) {
+	path, err := c.userPath("/projects/%s/jobs/%s/result", projectID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res JobResult
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// WatchJob calls fn with every event of the job until it finishes or fails,
+// fn returns an error or ctx is done. Dropped connections are resumed from
+// the last event received. The error of fn is returned as is.
+func (c *Client) WatchJob(ctx context.Context, projectID, jobID string, fn func(*JobEvent) error) error {
+	path, err := c.userPath("/projects/%s/jobs/%s/events", projectID, jobID)
+	if err != nil {
+		return err
+	}
+
+	w := &jobWatch{retry: defaultRetryInterval}
+	for {
+		done, err := c.watchJob(ctx, path, w, fn)
+		if done || err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.retry):
+		}
+	}
+}
+
+// jobWatch is the state of WatchJob kept across reconnections.
+type jobWatch struct {
+	lastEventID string
+	retry       time.Duration
+}
+
+// watchJob reads a single connection of the event stream. It returns done
+// once a final event was handled or the server has no more events, or false
+// when the stream ended early and should be resumed.
+func (c *Client) watchJob(ctx context.Context, path string, w *jobWatch, fn func(*JobEvent) error) (bool, error) {
+	r := &request{method: http.MethodGet, path: path, header: http.Header{}}
+	if w.lastEventID != "" {
+		r.header.Set("Last-Event-ID", w.lastEventID)
+	}
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	// the job was done before the stream started, there is nothing to wait
+	// for
+	if res.StatusCode == http.StatusNoContent {
+		return true, nil
+	}
+
+	var id string
+	var data strings.Builder
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF || (err != nil && ctx.Err() == nil) {
+			// connection dropped, resume it
+			return false, nil
+		}
+		if err != nil {
+			return false, ctx.Err()
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			e := &JobEvent{ID: id}
+			if err := json.Unmarshal([]byte(data.String()), e); err != nil {
+				return false, fmt.Errorf("decoding job event: %w", err)
+			}
+			data.Reset()
+			w.lastEventID = id
+
+			if err := fn(e); err != nil {
+				return false, err
+			}
+			if e.IsFinal() {
+				return true, nil
+			}
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(line[len("id:"):])
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(line[len("data:"):], " "))
+		case strings.HasPrefix(line, "retry:"):
+			var ms int
+			if _, err := fmt.Sscan(strings.TrimSpace(line[len("retry:"):]), &ms); err == nil && ms > 0 {
+				w.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
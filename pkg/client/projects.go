@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Project struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"userId"`
+	Name           string    `json:"name"`
+	Keywords       []string  `json:"keywords"`
+	Labels         []string  `json:"labels"`
+	Status         string    `json:"status"`
+	ResultImageURL string    `json:"resultImageUrl,omitempty"`
+	Job            *Job      `json:"job,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// PageOptions selects a page of a listing. The zero value is the first page
+// of the server's default size, newest first.
+type PageOptions struct {
+	Limit     int
+	Cursor    string
+	Ascending bool
+}
+
+func (o PageOptions) values() url.Values {
+	q := url.Values{}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Ascending {
+		q.Set("sort", "created_at")
+	}
+	return q
+}
+
+type ListProjectsOptions struct {
+	PageOptions
+
+	// NamePrefix keeps the projects whose name starts with it
+	NamePrefix string
+	Keyword    string
+
+	// Status of the latest job of the projects
+	Status        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// ProjectPage is a page of projects, NextCursor is empty on the last one.
+type ProjectPage struct {
+	Projects   []*Project `json:"projects"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+func (c *Client) ListProjects(ctx context.Context, opts ListProjectsOptions) (*ProjectPage, error) {
+	path, err := c.userPath("/projects")
+	if err != nil {
+		return nil, err
+	}
+
+	q := opts.values()
+	setQuery(q, "name", opts.NamePrefix)
+	setQuery(q, "keyword", opts.Keyword)
+	setQuery(q, "status", opts.Status)
+	setTimeQuery(q, "created_after", opts.CreatedAfter)
+	setTimeQuery(q, "created_before", opts.CreatedBefore)
+
+	var res ProjectPage
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path, query: q}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+type projectFields struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+}
+
+type projectResponse struct {
+	Project *Project `json:"project"`
+}
+
+func (c *Client) CreateProject(ctx context.Context, name string, keywords []string) (*Project, error) {
+	path, err := c.userPath("/projects")
+	if err != nil {
+		return nil, err
+	}
+
+	var res projectResponse
+	err = c.doJSON(ctx, &request{
+		method: http.MethodPost,
+		path:   path,
+		body:   jsonBody(projectFields{Name: name, Keywords: keywords}),
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Project, nil
+}
+
+func (c *Client) GetProject(ctx context.Context, projectID string) (*Project, error) {
+	path, err := c.userPath("/projects/%s", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res projectResponse
+	if err := c.doJSON(ctx, &request{method: http.MethodGet, path: path}, &res); err != nil {
+		return nil, err
+	}
+	return res.Project, nil
+}
+
+// UpdateProject replaces the name and keywords of the project.
+func (c *Client) UpdateProject(ctx context.Context, projectID, name string, keywords []string) (*Project, error) {
+	path, err := c.userPath("/projects/%s", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res projectResponse
+	err = c.doJSON(ctx, &request{
+		method: http.MethodPut,
+		path:   path,
+		body:   jsonBody(projectFields{Name: name, Keywords: keywords}),
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Project, nil
+}
+
+func (c *Client) DeleteProject(ctx context.Context, projectID string) error {
+	path, err := c.userPath("/projects/%s", projectID)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, &request{method: http.MethodDelete, path: path}, nil)
+}
+
+// Annotation export formats.
+const (
+	FormatCOCO = "coco"
+	FormatVOC  = "voc"
+)
+
+// ExportAnnotations returns the annotations of the project's images, a COCO
+// JSON document or a zip of Pascal VOC files. The caller must close it.
+func (c *Client) ExportAnnotations(ctx context.Context, projectID, format string) (io.ReadCloser, error) {
+	path, err := c.userPath("/projects/%s/annotations", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(ctx, &request{
+		method: http.MethodGet,
+		path:   path,
+		query:  url.Values{"format": {format}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func setQuery(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+func setTimeQuery(q url.Values, key string, t time.Time) {
+	if !t.IsZero() {
+		q.Set(key, t.Format(time.RFC3339Nano))
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/jobs"
+	"github.com/caquillo07/pyvinci-server/pkg/worker"
 )
 
 // Config is the application configuration
@@ -22,6 +25,10 @@ type Config struct {
 
 		// Allow CORS
 		AllowCORS bool
+
+		// How long the responses to requests with an Idempotency-Key
+		// header are kept for replaying
+		IdempotencyKeyTTL time.Duration
 	}
 	Auth struct {
 
@@ -34,6 +41,10 @@ type Config struct {
 
 	Database database.Config
 
+	Jobs jobs.Config
+
+	Worker worker.Config
+
 	S3 struct {
 		ImageBucket string
 		AccessKey   string
@@ -50,7 +61,20 @@ func InitViper(configFile string) {
 	viper.SetConfigFile(configFile)
 
 	// Default settings
+	viper.SetDefault("rest.idempotencyKeyTTL", "24h")
 	viper.SetDefault("auth.enabled", true)
+	viper.SetDefault("jobs.reapInterval", "1m")
+	viper.SetDefault("jobs.timeouts", map[string]string{
+		"pending_labels": "72h",
+		"processing":     "1h",
+	})
+	viper.SetDefault("jobs.maxAttempts", 3)
+	viper.SetDefault("jobs.scheduleInterval", "30s")
+	viper.SetDefault("jobs.maxConcurrentPerUser", 5)
+	viper.SetDefault("worker.processor", "fake")
+	viper.SetDefault("worker.concurrency", 1)
+	viper.SetDefault("worker.pollInterval", "5s")
+	viper.SetDefault("worker.jobTimeout", "30m")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv() // read in environment variables that match
 	if err := viper.ReadInConfig(); err != nil {
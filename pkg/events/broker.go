@@ -0,0 +1,101 @@
+// Package events delivers job and image changes happening in the database to
+// in-process subscribers, such as the job event streams or webhooks.
+package events
+
+import (
+	"sync"
+
+	"github.com/gofrs/uuid"
+	"go.uber.org/zap"
+)
+
+// size of the buffer of every subscription, when it fills up new events are
+// dropped for that subscriber.
+const subscriptionBufferSize = 64
+
+// JobEvent is published when a job changes status or progress.
+type JobEvent struct {
+	ID       int64     `json:"id"`
+	JobID    uuid.UUID `json:"jobId"`
+	Status   string    `json:"status"`
+	Progress *int      `json:"progress"`
+}
+
+// ImageEvent is published when an image is created, updated or deleted.
+type ImageEvent struct {
+	Op        string    `json:"op"`
+	ImageID   uuid.UUID `json:"imageId"`
+	ProjectID uuid.UUID `json:"projectId"`
+}
+
+// Event is a single change, only one of its fields is ever set.
+type Event struct {
+	Job   *JobEvent
+	Image *ImageEvent
+}
+
+// Broker fans out events to all of its subscribers.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: map[*Subscription]struct{}{},
+	}
+}
+
+// Subscribe registers a new subscriber. The caller must close the
+// subscription once it is no longer interested in events.
+func (b *Broker) Subscribe() *Subscription {
+	sub := &Subscription{
+		broker: b,
+		events: make(chan Event, subscriptionBufferSize),
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish sends the event to every subscriber. Publish never blocks, slow
+// subscribers miss events and are expected to catch up from the database.
+func (b *Broker) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		select {
+		case sub.events <- e:
+		default:
+			zap.L().Warn("dropping event for slow subscriber")
+		}
+	}
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.events)
+}
+
+// Subscription receives the events published on a Broker.
+type Subscription struct {
+	broker *Broker
+	events chan Event
+}
+
+// Events returns the channel the events are delivered on. The channel is
+// closed when the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the delivery of events to this subscription.
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
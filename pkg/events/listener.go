@@ -0,0 +1,228 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// Channels notified by the database triggers.
+const (
+	ChannelJobEvents   = "job_events"
+	ChannelImageEvents = "image_events"
+)
+
+const (
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+
+	// if nothing is heard from the database for this long, the connection is
+	// pinged to make sure it is still alive.
+	pingInterval = 90 * time.Second
+)
+
+// Listener listens for the notifications sent by the database and publishes
+// them on a Broker. The underlying connection is re-established automatically,
+// and after every reconnect the events missed while disconnected are loaded
+// from the database and published.
+type Listener struct {
+	connectionString string
+	db               *gorm.DB
+	broker           *Broker
+
+	lastJobEventID int64
+	lastImageSync  time.Time
+}
+
+func NewListener(connectionString string, db *gorm.DB, broker *Broker) *Listener {
+	return &Listener{
+		connectionString: connectionString,
+		db:               db,
+		broker:           broker,
+	}
+}
+
+// Run listens for notifications until the context is cancelled. Setting up
+// the listener is retried with backoff, so the server can start before the
+// database is reachable.
+func (l *Listener) Run(ctx context.Context) {
+	listener, ok := l.start(ctx)
+	if !ok {
+		return
+	}
+
+	// events sent after loading where they were left but before listening
+	// were not heard
+	l.backfill()
+	defer func() {
+		if err := listener.Close(); err != nil {
+			zap.L().Error("error closing database listener", zap.Error(err))
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// a nil notification means the connection was lost and has been
+			// re-established, anything sent in between is gone.
+			if n == nil {
+				zap.L().Info("database listener reconnected, backfilling events")
+				l.backfill()
+				continue
+			}
+			l.handleNotification(n)
+		case <-time.After(pingInterval):
+			go func() {
+				if err := listener.Ping(); err != nil {
+					zap.L().Error("database listener ping failed", zap.Error(err))
+				}
+			}()
+		}
+	}
+}
+
+// start sets up the listener, retrying every minReconnectInterval, doubled
+// after every failure up to maxReconnectInterval, until it succeeds or the
+// context is cancelled.
+func (l *Listener) start(ctx context.Context) (*pq.Listener, bool) {
+	delay := minReconnectInterval
+	for {
+		listener, err := l.listen()
+		if err == nil {
+			return listener, true
+		}
+		zap.L().Error(
+			"failed to start database listener, will retry",
+			zap.Duration("retry_in", delay),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectInterval {
+			delay = maxReconnectInterval
+		}
+	}
+}
+
+// listen loads where the events were left and listens on the channels.
+func (l *Listener) listen() (*pq.Listener, error) {
+	lastID, err := model.LastJobEventID(l.db)
+	if err != nil {
+		return nil, err
+	}
+	now, err := database.Now(l.db)
+	if err != nil {
+		return nil, err
+	}
+	l.lastJobEventID = lastID
+	l.lastImageSync = now
+
+	listener := pq.NewListener(
+		l.connectionString,
+		minReconnectInterval,
+		maxReconnectInterval,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				zap.L().Error("database listener error", zap.Error(err))
+			}
+		},
+	)
+	for _, channel := range []string{ChannelJobEvents, ChannelImageEvents} {
+		if err := listener.Listen(channel); err != nil {
+			if err := listener.Close(); err != nil {
+				zap.L().Error("error closing database listener", zap.Error(err))
+			}
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+func (l *Listener) handleNotification(n *pq.Notification) {
+	switch n.Channel {
+	case ChannelJobEvents:
+		var e JobEvent
+		if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+			zap.L().Error("invalid job event notification", zap.Error(err))
+			return
+		}
+
+		if e.ID > l.lastJobEventID {
+			l.lastJobEventID = e.ID
+		}
+		l.broker.Publish(Event{Job: &e})
+	case ChannelImageEvents:
+		var e ImageEvent
+		if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+			zap.L().Error("invalid image event notification", zap.Error(err))
+			return
+		}
+		// keeping the previous sync time only backfills more images
+		if now, err := database.Now(l.db); err == nil {
+			l.lastImageSync = now
+		}
+		l.broker.Publish(Event{Image: &e})
+	}
+}
+
+func (l *Listener) backfill() {
+	l.backfillJobEvents()
+	l.backfillImageEvents()
+}
+
+func (l *Listener) backfillJobEvents() {
+	events, err := model.AllJobEventsSince(l.db, l.lastJobEventID)
+	if err != nil {
+		zap.L().Error("failed to backfill job events", zap.Error(err))
+		return
+	}
+
+	for _, e := range events {
+		l.broker.Publish(Event{Job: &JobEvent{
+			ID:       e.ID,
+			JobID:    e.JobID,
+			Status:   e.Status,
+			Progress: e.Progress,
+		}})
+		l.lastJobEventID = e.ID
+	}
+}
+
+// backfillImageEvents publishes an update for every image modified since the
+// last backfill. Deleted images cannot be recovered this way. The sync times
+// come from the database clock, which every server shares, rather than from
+// the clock of this one.
+func (l *Listener) backfillImageEvents() {
+	now, err := database.Now(l.db)
+	if err != nil {
+		zap.L().Error("failed to backfill image events", zap.Error(err))
+		return
+	}
+	images, err := model.ImagesUpdatedSince(l.db, l.lastImageSync)
+	if err != nil {
+		zap.L().Error("failed to backfill image events", zap.Error(err))
+		return
+	}
+
+	for _, img := range images {
+		l.broker.Publish(Event{Image: &ImageEvent{
+			Op:        "UPDATE",
+			ImageID:   img.ID,
+			ProjectID: img.ProjectID,
+		}})
+	}
+	l.lastImageSync = now
+}
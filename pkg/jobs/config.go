@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"strings"
+	"time"
+)
+
+// Config provides the job lifecycle configuration
+type Config struct {
+
+	// How often the reaper looks for stuck jobs
+	ReapInterval time.Duration
+
+	// How long a job may stay in a status without being updated before it is
+	// considered stuck, keyed by status. Statuses without a timeout are never
+	// reaped. Processing jobs are updated by their worker as they make
+	// progress, while pending jobs wait in the queue for as long as the
+	// workers are busy, so their timeout should be much longer.
+	Timeouts map[string]time.Duration
+
+	// How many times a stuck job is put back in the queue before it is
+	// marked as failed
+	MaxAttempts int
+
+	// How often queued jobs are scheduled, besides every time a job is done
+	ScheduleInterval time.Duration
+
+	// Maximum number of jobs pending or being processed at once, across all
+	// users. Zero means unlimited
+	MaxConcurrent int
+
+	// Maximum number of jobs a single user may have pending or being
+	// processed at once, unless the user has its own limit. Zero means
+	// unlimited
+	MaxConcurrentPerUser int
+}
+
+// Timeout returns the timeout configured for the given status. Keys are
+// matched case insensitively, as the configuration loader lower cases them.
+func (c Config) Timeout(status string) (time.Duration, bool) {
+	for s, timeout := range c.Timeouts {
+		if strings.EqualFold(s, status) {
+			return timeout, true
+		}
+	}
+	return 0, false
+}
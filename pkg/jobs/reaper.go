@@ -0,0 +1,146 @@
+// Package jobs manages the lifecycle of the jobs processed by the workers.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// Reaper finds jobs that have not been updated for longer than the timeout of
+// their status. A processing job that stopped being updated usually means the
+// worker handling it went away, so it is put back in the queue, or marked as
+// failed once it runs out of attempts. Pending jobs are not held by any
+// worker, they are only failed once they waited for a worker for longer than
+// their timeout.
+type Reaper struct {
+	db     *gorm.DB
+	config Config
+}
+
+func NewReaper(db *gorm.DB, config Config) *Reaper {
+	return &Reaper{
+		db:     db,
+		config: config,
+	}
+}
+
+// Run reaps stuck jobs every ReapInterval until the context is cancelled.
+func (r *Reaper) Run(ctx context.Context) {
+	if r.config.ReapInterval <= 0 {
+		zap.L().Info("job reaper disabled")
+		return
+	}
+
+	ticker := time.NewTicker(r.config.ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reap(); err != nil {
+				zap.L().Error("failed to reap jobs", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Reap does a single pass over the stuck jobs, returning how many of them
+// were re-queued or failed.
+func (r *Reaper) Reap() (int, error) {
+	processing, err := r.reapStatus(model.JobStatusProcessing, r.reap)
+	if err != nil {
+		return processing, err
+	}
+	pending, err := r.reapStatus(model.JobStatusPendingLabels, r.expire)
+	return processing + pending, err
+}
+
+// reapStatus hands the jobs stuck in the given status to reap, skipping the
+// statuses without a timeout.
+func (r *Reaper) reapStatus(
+	status string,
+	reap func(job *model.Job, timeout time.Duration) (bool, error),
+) (int, error) {
+	timeout, ok := r.config.Timeout(status)
+	if !ok || timeout <= 0 {
+		return 0, nil
+	}
+
+	jobs, err := model.FindStaleJobs(r.db, status, time.Now().Add(-timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	reaped := 0
+	for _, job := range jobs {
+		ok, err := reap(job, timeout)
+		if err != nil {
+			return reaped, err
+		}
+		if ok {
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+func (r *Reaper) reap(job *model.Job, timeout time.Duration) (bool, error) {
+	log := zap.L().With(
+		zap.String("job_id", job.ID.String()),
+		zap.String("project_id", job.ProjectID.String()),
+		zap.String("status", job.Status),
+		zap.Int("attempts", job.Attempts),
+		zap.Time("updated_at", job.UpdatedAt),
+	)
+
+	if job.Attempts < r.config.MaxAttempts {
+		ok, err := model.RequeueJob(r.db, job)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			log.Info("stuck job changed while reaping, skipping")
+			return false, nil
+		}
+		log.Warn("re-queued stuck job", zap.Duration("timeout", timeout))
+		return true, nil
+	}
+
+	reason := fmt.Sprintf("timed out after %s in status %s", timeout, job.Status)
+	return r.fail(job, reason, timeout, log)
+}
+
+// expire fails a job no worker claimed within the timeout. Putting it back in
+// the queue would not help, it never left it.
+func (r *Reaper) expire(job *model.Job, timeout time.Duration) (bool, error) {
+	log := zap.L().With(
+		zap.String("job_id", job.ID.String()),
+		zap.String("project_id", job.ProjectID.String()),
+		zap.String("status", job.Status),
+		zap.Time("updated_at", job.UpdatedAt),
+	)
+
+	reason := fmt.Sprintf("no worker picked the job up within %s", timeout)
+	return r.fail(job, reason, timeout, log)
+}
+
+func (r *Reaper) fail(job *model.Job, reason string, timeout time.Duration, log *zap.Logger) (bool, error) {
+	ok, err := model.FailJob(r.db, job, reason)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		log.Info("stuck job changed while reaping, skipping")
+		return false, nil
+	}
+	log.Warn("failed stuck job", zap.Duration("timeout", timeout))
+	return true, nil
+}
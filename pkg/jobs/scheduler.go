@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/events"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// key of the advisory lock held while scheduling, so only one server promotes
+// jobs at a time.
+const scheduleLockKey = 7031001
+
+// Scheduler moves queued jobs to pending, where workers can claim them,
+// honoring the global and per user concurrency limits.
+type Scheduler struct {
+	db     *gorm.DB
+	config Config
+}
+
+func NewScheduler(db *gorm.DB, config Config) *Scheduler {
+	return &Scheduler{
+		db:     db,
+		config: config,
+	}
+}
+
+// Run schedules jobs every time a job finishes or fails, and every
+// ScheduleInterval in case an event was missed, until the context is
+// cancelled.
+func (s *Scheduler) Run(ctx context.Context, broker *events.Broker) {
+	sub := broker.Subscribe()
+	defer sub.Close()
+
+	interval := s.config.ScheduleInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if e.Job == nil || !isFinalStatus(e.Job.Status) {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if _, err := s.Schedule(ctx); err != nil {
+			zap.L().Error("failed to schedule jobs", zap.Error(err))
+		}
+	}
+}
+
+// Schedule promotes as many queued jobs as the limits allow, returning the
+// promoted jobs.
+func (s *Scheduler) Schedule(ctx context.Context) ([]*model.Job, error) {
+	var promoted []*model.Job
+	err := database.Transact(ctx, s.db, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", scheduleLockKey).Error; err != nil {
+			return err
+		}
+
+		for {
+			if s.config.MaxConcurrent > 0 {
+				active, err := model.CountActiveJobs(tx)
+				if err != nil {
+					return err
+				}
+				if active >= s.config.MaxConcurrent {
+					return nil
+				}
+			}
+
+			job, err := model.PromoteNextQueuedJob(tx, s.config.MaxConcurrentPerUser)
+			if gorm.IsRecordNotFoundError(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			promoted = append(promoted, job)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range promoted {
+		zap.L().Info(
+			"scheduled job",
+			zap.String("job_id", job.ID.String()),
+			zap.String("project_id", job.ProjectID.String()),
+			zap.Int("priority", job.Priority),
+		)
+	}
+	return promoted, nil
+}
+
+func isFinalStatus(status string) bool {
+	return status == model.JobStatusFinished || status == model.JobStatusFailed
+}
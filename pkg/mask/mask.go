@@ -0,0 +1,309 @@
+// Package mask encodes and decodes the segmentation masks stored along with
+// the images, and renders them as PNG overlays.
+//
+// The masks of an image are stored in the image.masks column, one mask per
+// entry of image.masks_labels and in the same order. All the masks of an image
+// share the same size. The stored format is:
+//
+//	magic     4 bytes   "PVM1"
+//	width     uint16    big endian
+//	height    uint16    big endian
+//	count     uint16    big endian, number of masks
+//	masks     count times:
+//	  runs    uvarint   number of runs in the mask
+//	  run     uvarint   repeated runs times
+//
+// Every mask is run length encoded in row major order. Runs alternate between
+// unset and set pixels, always starting with unset, so a mask starting with a
+// set pixel begins with a run of length zero. The runs of a mask add up to
+// width * height.
+package mask
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+)
+
+const magic = "PVM1"
+
+// maxDecodedPixels bounds the pixels of all the masks decoded at once, about
+// twenty masks of a 12 megapixel image.
+const maxDecodedPixels = 1 << 28
+
+var (
+	ErrInvalidFormat = errors.New("mask: invalid format")
+	ErrSizeMismatch  = errors.New("mask: masks must all have the same size")
+	ErrTooLarge      = errors.New("mask: masks are too large to decode")
+)
+
+// Mask is a binary segmentation mask.
+type Mask struct {
+	Width  int
+	Height int
+
+	// Pix holds the pixels in row major order
+	Pix []bool
+}
+
+// New returns an empty mask of the given size.
+func New(width, height int) *Mask {
+	return &Mask{
+		Width:  width,
+		Height: height,
+		Pix:    make([]bool, width*height),
+	}
+}
+
+// At reports whether the pixel at x, y is set.
+func (m *Mask) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return false
+	}
+	return m.Pix[y*m.Width+x]
+}
+
+// Set sets or unsets the pixel at x, y.
+func (m *Mask) Set(x, y int, v bool) {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return
+	}
+	m.Pix[y*m.Width+x] = v
+}
+
+// Runs returns the run length encoding of the mask in row major order,
+// alternating between unset and set pixels starting with unset.
+func (m *Mask) Runs() []uint64 {
+	return runs(len(m.Pix), func(i int) bool { return m.Pix[i] })
+}
+
+// ColumnRuns returns the run length encoding of the mask in column major
+// order, which is what COCO uses for its RLE segmentations.
+func (m *Mask) ColumnRuns() []uint64 {
+	return columnRuns(m.Width, m.Height, m.At)
+}
+
+// Area returns the number of set pixels.
+func (m *Mask) Area() int {
+	return area(m.Width, m.Height, m.At)
+}
+
+// Bounds returns the smallest rectangle containing all the set pixels, which
+// is empty if there are none.
+func (m *Mask) Bounds() image.Rectangle {
+	return bounds(m.Width, m.Height, m.At)
+}
+
+// Resize returns a copy of the mask scaled to the given size, using nearest
+// neighbor sampling.
+func (m *Mask) Resize(width, height int) *Mask {
+	res := New(width, height)
+	scaled := m.Scaled(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			res.Pix[y*width+x] = scaled.At(x, y)
+		}
+	}
+	return res
+}
+
+// Scaled is a mask seen at another size, using nearest neighbor sampling.
+// Unlike Resize no pixels are copied, they are looked up in the mask as they
+// are read.
+type Scaled struct {
+	Width  int
+	Height int
+
+	m *Mask
+}
+
+// Scaled returns the mask seen at the given size.
+func (m *Mask) Scaled(width, height int) *Scaled {
+	return &Scaled{Width: width, Height: height, m: m}
+}
+
+// At reports whether the pixel at x, y is set.
+func (s *Scaled) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= s.Width || y >= s.Height {
+		return false
+	}
+	return s.m.At(x*s.m.Width/s.Width, y*s.m.Height/s.Height)
+}
+
+// ColumnRuns returns the run length encoding of the scaled mask in column
+// major order.
+func (s *Scaled) ColumnRuns() []uint64 {
+	return columnRuns(s.Width, s.Height, s.At)
+}
+
+// Area returns the number of set pixels of the scaled mask.
+func (s *Scaled) Area() int {
+	return area(s.Width, s.Height, s.At)
+}
+
+// Bounds returns the smallest rectangle containing all the set pixels of the
+// scaled mask, which is empty if there are none.
+func (s *Scaled) Bounds() image.Rectangle {
+	return bounds(s.Width, s.Height, s.At)
+}
+
+func columnRuns(width, height int, at func(x, y int) bool) []uint64 {
+	return runs(width*height, func(i int) bool { return at(i/height, i%height) })
+}
+
+func area(width, height int, at func(x, y int) bool) int {
+	res := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if at(x, y) {
+				res++
+			}
+		}
+	}
+	return res
+}
+
+func bounds(width, height int, at func(x, y int) bool) image.Rectangle {
+	minX, minY, maxX, maxY := width, height, -1, -1
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if !at(x, y) {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+		}
+	}
+	if maxX < 0 {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX+1, maxY+1)
+}
+
+func runs(n int, at func(i int) bool) []uint64 {
+	var res []uint64
+	current, run := false, uint64(0)
+	for i := 0; i < n; i++ {
+		if set := at(i); set != current {
+			res = append(res, run)
+			current, run = set, 0
+		}
+		run++
+	}
+	return append(res, run)
+}
+
+// Encode encodes the masks in the stored format.
+func Encode(masks []*Mask) ([]byte, error) {
+	var width, height int
+	if len(masks) > 0 {
+		width, height = masks[0].Width, masks[0].Height
+	}
+	if width > 0xffff || height > 0xffff || len(masks) > 0xffff {
+		return nil, fmt.Errorf("mask: %d masks of %dx%d are too large to encode", len(masks), width, height)
+	}
+	if uint64(width)*uint64(height)*uint64(len(masks)) > maxDecodedPixels {
+		return nil, ErrTooLarge
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	header := make([]byte, 6)
+	binary.BigEndian.PutUint16(header[0:], uint16(width))
+	binary.BigEndian.PutUint16(header[2:], uint16(height))
+	binary.BigEndian.PutUint16(header[4:], uint16(len(masks)))
+	buf.Write(header)
+
+	tmp := make([]byte, binary.MaxVarintLen64)
+	for _, m := range masks {
+		if m.Width != width || m.Height != height {
+			return nil, ErrSizeMismatch
+		}
+
+		runs := m.Runs()
+		buf.Write(tmp[:binary.PutUvarint(tmp, uint64(len(runs)))])
+		for _, r := range runs {
+			buf.Write(tmp[:binary.PutUvarint(tmp, r)])
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes masks in the stored format. The whole input is checked
+// before any pixel is allocated, so a header claiming more masks or pixels
+// than the data holds is rejected up front.
+func Decode(data []byte) ([]*Mask, error) {
+	if len(data) < len(magic)+6 || string(data[:len(magic)]) != magic {
+		return nil, ErrInvalidFormat
+	}
+	data = data[len(magic):]
+	width := int(binary.BigEndian.Uint16(data[0:]))
+	height := int(binary.BigEndian.Uint16(data[2:]))
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	r := bytes.NewReader(data[6:])
+
+	// every mask takes at least a byte
+	if count > r.Len() {
+		return nil, ErrInvalidFormat
+	}
+	if uint64(width)*uint64(height)*uint64(count) > maxDecodedPixels {
+		return nil, ErrTooLarge
+	}
+
+	size := uint64(width * height)
+	maskRuns := make([][]uint64, count)
+	for i := range maskRuns {
+		n, err := binary.ReadUvarint(r)
+		// every run takes at least a byte too
+		if err != nil || n > uint64(r.Len()) {
+			return nil, ErrInvalidFormat
+		}
+
+		runs := make([]uint64, n)
+		total := uint64(0)
+		for j := range runs {
+			run, err := binary.ReadUvarint(r)
+			if err != nil || run > size-total {
+				return nil, ErrInvalidFormat
+			}
+			runs[j] = run
+			total += run
+		}
+		if total != size {
+			return nil, ErrInvalidFormat
+		}
+		maskRuns[i] = runs
+	}
+	if r.Len() != 0 {
+		return nil, ErrInvalidFormat
+	}
+
+	masks := make([]*Mask, count)
+	for i, runs := range maskRuns {
+		m := New(width, height)
+		pos, set := 0, false
+		for _, run := range runs {
+			if set {
+				for k := pos; k < pos+int(run); k++ {
+					m.Pix[k] = true
+				}
+			}
+			pos += int(run)
+			set = !set
+		}
+		masks[i] = m
+	}
+	return masks, nil
+}
@@ -0,0 +1,137 @@
+package mask
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+// newMask builds a mask from rows of 0s and 1s.
+func newMask(rows ...string) *Mask {
+	m := New(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			m.Set(x, y, c == '1')
+		}
+	}
+	return m
+}
+
+func TestScaled(t *testing.T) {
+	m := newMask(
+		"0110",
+		"0100",
+		"0000",
+	)
+
+	sizes := []image.Point{{4, 3}, {8, 6}, {2, 2}, {5, 7}, {1, 1}, {0, 0}}
+	for _, size := range sizes {
+		scaled := m.Scaled(size.X, size.Y)
+		resized := m.Resize(size.X, size.Y)
+
+		if got, want := scaled.ColumnRuns(), resized.ColumnRuns(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: ColumnRuns() = %v, want %v", size, got, want)
+		}
+		if got, want := scaled.Area(), resized.Area(); got != want {
+			t.Errorf("%v: Area() = %d, want %d", size, got, want)
+		}
+		if got, want := scaled.Bounds(), resized.Bounds(); got != want {
+			t.Errorf("%v: Bounds() = %v, want %v", size, got, want)
+		}
+	}
+
+	if got, want := m.Scaled(8, 6).Bounds(), image.Rect(2, 0, 6, 4); got != want {
+		t.Errorf("Bounds() = %v, want %v", got, want)
+	}
+	if got, want := m.ColumnRuns(), []uint64{3, 2, 1, 1, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ColumnRuns() = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		masks []*Mask
+	}{
+		{"none", []*Mask{}},
+		{"single", []*Mask{newMask("0110", "0100")}},
+		{"starting set", []*Mask{newMask("1100", "0001"), newMask("1111", "1111"), newMask("0000", "0000")}},
+		{"single pixel", []*Mask{newMask("1"), newMask("0")}},
+		{"empty masks", []*Mask{New(0, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(tt.masks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.masks) {
+				t.Fatalf("Decode() returned %d masks, want %d", len(got), len(tt.masks))
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], tt.masks[i]) {
+					t.Errorf("mask %d = %+v, want %+v", i, got[i], tt.masks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, err := Encode([]*Mask{New(2, 2), New(2, 3)}); err != ErrSizeMismatch {
+		t.Errorf("Encode() of masks of different sizes error = %v, want %v", err, ErrSizeMismatch)
+	}
+	if _, err := Encode([]*Mask{New(0x10000, 1)}); err == nil {
+		t.Error("Encode() of a mask too wide for the header succeeded")
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	valid, err := Encode([]*Mask{newMask("0110", "0100")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// header builds the magic and header of count masks of width x height
+	header := func(width, height, count byte) []byte {
+		return []byte{'P', 'V', 'M', '1', 0, width, 0, height, 0, count}
+	}
+	with := func(b []byte, rest ...byte) []byte {
+		return append(append([]byte{}, b...), rest...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrInvalidFormat},
+		{"short header", valid[:7], ErrInvalidFormat},
+		{"bad magic", with([]byte("PVM2"), valid[4:]...), ErrInvalidFormat},
+		{"missing masks", header(4, 2, 1), ErrInvalidFormat},
+		{"more masks than bytes", with(header(4, 2, 200), 1, 8), ErrInvalidFormat},
+		{"more runs than bytes", with(header(4, 2, 1), 0xff, 0xff, 0x03, 8), ErrInvalidFormat},
+		{"truncated runs", valid[:len(valid)-1], ErrInvalidFormat},
+		{"runs too short", with(header(4, 2, 1), 2, 3, 4), ErrInvalidFormat},
+		{"runs too long", with(header(4, 2, 1), 2, 3, 6), ErrInvalidFormat},
+		{"run overflowing", with(header(4, 2, 1), 2, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01), ErrInvalidFormat},
+		{"truncated varint", with(header(4, 2, 1), 1, 0x80), ErrInvalidFormat},
+		{"trailing data", with(valid, 0), ErrInvalidFormat},
+		{"too many pixels", with([]byte{'P', 'V', 'M', '1', 0xff, 0xff, 0xff, 0xff, 0, 2}, make([]byte, 16)...), ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masks, err := Decode(tt.data)
+			if err != tt.want {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+			if masks != nil {
+				t.Errorf("Decode() = %v, want no masks", masks)
+			}
+		})
+	}
+}
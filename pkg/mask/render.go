@@ -0,0 +1,106 @@
+package mask
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+)
+
+// overlayAlpha is the opacity of the set pixels of an overlay
+const overlayAlpha = 0x99
+
+// palette of distinguishable colors, labels are assigned one by hashing their
+// name so a label keeps its color across images.
+var palette = []color.NRGBA{
+	{R: 0xe6, G: 0x19, B: 0x4b},
+	{R: 0x3c, G: 0xb4, B: 0x4b},
+	{R: 0xff, G: 0xe1, B: 0x19},
+	{R: 0x43, G: 0x63, B: 0xd8},
+	{R: 0xf5, G: 0x82, B: 0x31},
+	{R: 0x91, G: 0x1e, B: 0xb4},
+	{R: 0x46, G: 0xf0, B: 0xf0},
+	{R: 0xf0, G: 0x32, B: 0xe6},
+	{R: 0xbc, G: 0xf6, B: 0x0c},
+	{R: 0xfa, G: 0xbe, B: 0xbe},
+	{R: 0x00, G: 0x80, B: 0x80},
+	{R: 0xe6, G: 0xbe, B: 0xff},
+	{R: 0x9a, G: 0x63, B: 0x24},
+	{R: 0xff, G: 0xfa, B: 0xc8},
+	{R: 0x80, G: 0x00, B: 0x00},
+	{R: 0xaa, G: 0xff, B: 0xc3},
+	{R: 0x80, G: 0x80, B: 0x00},
+	{R: 0xff, G: 0xd8, B: 0xb1},
+	{R: 0x00, G: 0x00, B: 0x75},
+	{R: 0x80, G: 0x80, B: 0x80},
+}
+
+// LabelColor returns the color used to draw the given label.
+func LabelColor(label string) color.NRGBA {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(label))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// HexColor formats the color as #rrggbb.
+func HexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Overlay renders the mask as a transparent image, with the set pixels in the
+// given color.
+func Overlay(m *Mask, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, m.Width, m.Height))
+	c.A = overlayAlpha
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.At(x, y) {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// LegendEntry tells which color a label was drawn with.
+type LegendEntry struct {
+	Label string
+	Color color.NRGBA
+}
+
+// Colorize renders all the masks of an image on a single transparent image,
+// each label in its own color. Masks later in the list are drawn on top.
+func Colorize(masks []*Mask, labels []string) (*image.NRGBA, []LegendEntry, error) {
+	if len(masks) != len(labels) {
+		return nil, nil, fmt.Errorf("mask: %d masks but %d labels", len(masks), len(labels))
+	}
+	if len(masks) == 0 {
+		return image.NewNRGBA(image.Rect(0, 0, 0, 0)), []LegendEntry{}, nil
+	}
+
+	width, height := masks[0].Width, masks[0].Height
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	legend := make([]LegendEntry, 0, len(labels))
+	seen := map[string]bool{}
+	for i, m := range masks {
+		if m.Width != width || m.Height != height {
+			return nil, nil, ErrSizeMismatch
+		}
+
+		c := LabelColor(labels[i])
+		if !seen[labels[i]] {
+			seen[labels[i]] = true
+			legend = append(legend, LegendEntry{Label: labels[i], Color: c})
+		}
+
+		c.A = overlayAlpha
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if m.At(x, y) {
+					img.SetNRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img, legend, nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// IdempotencyKey holds the response to the first request made with a given
+// Idempotency-Key header, so retries of that request can be replayed.
+type IdempotencyKey struct {
+	UserID       uuid.UUID `gorm:"column:user_record;primary_key"`
+	Key          string    `gorm:"primary_key"`
+	Fingerprint  string
+	StatusCode   *int
+	ContentType  *string
+	ResponseBody []byte
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsCompleted reports whether the response of the first request was stored.
+func (k *IdempotencyKey) IsCompleted() bool {
+	return k.StatusCode != nil
+}
+
+// ReserveIdempotencyKey stores the key before the request is processed. It
+// returns false when the key is already taken and has not expired, in that
+// case the existing key should be loaded with FindIdempotencyKey.
+func ReserveIdempotencyKey(db *gorm.DB, k *IdempotencyKey) (bool, error) {
+	now := time.Now()
+	if err := db.Delete(
+		&IdempotencyKey{},
+		"user_record = ? AND key = ? AND expires_at < ?",
+		k.UserID,
+		k.Key,
+		now,
+	).Error; err != nil {
+		return false, err
+	}
+
+	k.CreatedAt, k.UpdatedAt = now, now
+	res := db.Exec(`
+		INSERT INTO idempotency_key (user_record, key, fingerprint, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		k.UserID, k.Key, k.Fingerprint, k.ExpiresAt, k.CreatedAt, k.UpdatedAt,
+	)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func FindIdempotencyKey(db *gorm.DB, userID uuid.UUID, key string) (*IdempotencyKey, error) {
+	var k IdempotencyKey
+	if err := db.Where("user_record = ? AND key = ?", userID, key).Take(&k).Error; err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CompleteIdempotencyKey stores the response sent for the key.
+func CompleteIdempotencyKey(
+	db *gorm.DB,
+	k *IdempotencyKey,
+	statusCode int,
+	contentType string,
+	body []byte,
+) error {
+	k.StatusCode = &statusCode
+	k.ContentType = &contentType
+	k.ResponseBody = body
+	return db.Model(k).Updates(map[string]interface{}{
+		"status_code":   statusCode,
+		"content_type":  contentType,
+		"response_body": body,
+	}).Error
+}
+
+// ReleaseIdempotencyKey deletes the key so the request can be retried, used
+// when the first request failed unexpectedly.
+func ReleaseIdempotencyKey(db *gorm.DB, k *IdempotencyKey) error {
+	return db.Delete(&IdempotencyKey{}, "user_record = ? AND key = ?", k.UserID, k.Key).Error
+}
+
+// DeleteExpiredIdempotencyKeys removes every expired key, returning how many
+// were removed.
+func DeleteExpiredIdempotencyKeys(db *gorm.DB) (int64, error) {
+	res := db.Delete(&IdempotencyKey{}, "expires_at < ?", time.Now())
+	return res.RowsAffected, res.Error
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -9,13 +12,78 @@ import (
 )
 
 type Image struct {
-	ID          uuid.UUID
-	ProjectID   uuid.UUID
-	URL         string
-	LabelsStuff pq.StringArray
-	MasksLabels pq.StringArray
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID           uuid.UUID
+	ProjectID    uuid.UUID
+	URL          string
+	Checksum     *string
+	Width        *int
+	Height       *int
+	LabelsThings pq.StringArray
+	LabelsStuff  pq.StringArray
+	MasksLabels  pq.StringArray
+	LabelScores  LabelScores
+
+	// Labels corrected by the user, nil when never corrected
+	UserLabelsThings pq.StringArray
+	UserLabelsStuff  pq.StringArray
+
+	// Version is bumped by the database on every update
+	Version int `gorm:"default:1"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// LabelScores holds the confidence of the model, from 0 to 1, for each label
+// of an image. Not every model reports scores, so it may be empty.
+type LabelScores map[string]float64
+
+func (s LabelScores) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *LabelScores) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into LabelScores", src)
+	}
+}
+
+// EffectiveThings returns the things labels, as corrected by the user if they
+// ever were.
+func (i *Image) EffectiveThings() []string {
+	if i.UserLabelsThings != nil {
+		return i.UserLabelsThings
+	}
+	return i.LabelsThings
+}
+
+// EffectiveStuff returns the stuff labels, as corrected by the user if they
+// ever were.
+func (i *Image) EffectiveStuff() []string {
+	if i.UserLabelsStuff != nil {
+		return i.UserLabelsStuff
+	}
+	return i.LabelsStuff
+}
+
+// IsLabelsEdited reports whether the user ever corrected the image labels.
+func (i *Image) IsLabelsEdited() bool {
+	return i.UserLabelsThings != nil || i.UserLabelsStuff != nil
 }
 
 func CreateImage(db *gorm.DB, img *Image) error {
@@ -30,6 +98,74 @@ func AllImagesForProject(db *gorm.DB, projectID uuid.UUID) ([]*Image, error) {
 	return i, nil
 }
 
+// ImageFilter narrows down the images returned by ListImagesForProject.
+// Zero values do not filter.
+type ImageFilter struct {
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// ListImagesForProject returns a page of the images of the project matching
+// the filter, and the cursor of the next page, nil on the last one.
+func ListImagesForProject(
+	db *gorm.DB,
+	projectID uuid.UUID,
+	filter ImageFilter,
+	page Page,
+) ([]*Image, *Cursor, error) {
+	q := db.Where("image.project_id = ?", projectID)
+	if filter.CreatedAfter != nil {
+		q = q.Where("image.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		q = q.Where("image.created_at < ?", *filter.CreatedBefore)
+	}
+
+	var i []*Image
+	if err := page.apply(q, "image").Find(&i).Error; err != nil {
+		return nil, nil, err
+	}
+	return imagesPage(i, page)
+}
+
+// SearchImagesForUser returns a page of the images, across all the projects
+// the user is a member of, that carry all of the given labels, or any of them when
+// matchAll is false. Things and stuff are both considered, as corrected by
+// the user.
+func SearchImagesForUser(
+	db *gorm.DB,
+	userID uuid.UUID,
+	labels []string,
+	matchAll bool,
+	page Page,
+) ([]*Image, *Cursor, error) {
+	op := "&&"
+	if matchAll {
+		op = "@>"
+	}
+
+	q := db.Select("image.*").
+		Joins("JOIN project_member ON project_member.project_id = image.project_id").
+		Where("project_member.user_record = ?", userID).
+		Where("image.labels_all "+op+" ?", pq.StringArray(labels))
+
+	var i []*Image
+	if err := page.apply(q, "image").Find(&i).Error; err != nil {
+		return nil, nil, err
+	}
+	return imagesPage(i, page)
+}
+
+func imagesPage(i []*Image, page Page) ([]*Image, *Cursor, error) {
+	next := page.nextCursor(len(i), func(n int) (time.Time, uuid.UUID) {
+		return i[n].CreatedAt, i[n].ID
+	})
+	if next != nil {
+		i = i[:page.Limit]
+	}
+	return i, next, nil
+}
+
 func FindImageByID(db *gorm.DB, id uuid.UUID) (*Image, error) {
 	var i Image
 	if err := db.Where("id = ?", id).Take(&i).Error; err != nil {
@@ -38,6 +174,130 @@ func FindImageByID(db *gorm.DB, id uuid.UUID) (*Image, error) {
 	return &i, nil
 }
 
+// ImagesUpdatedSince returns the images created or modified after t.
+func ImagesUpdatedSince(db *gorm.DB, t time.Time) ([]*Image, error) {
+	var i []*Image
+	if err := db.Where("updated_at > ?", t).Order("updated_at").Find(&i).Error; err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// FindImagesByIDs returns the images with the given IDs, images that do not
+// exist are left out.
+func FindImagesByIDs(db *gorm.DB, ids []uuid.UUID) ([]*Image, error) {
+	var i []*Image
+	if len(ids) == 0 {
+		return i, nil
+	}
+	if err := db.Where("id IN (?)", ids).Order("created_at").Find(&i).Error; err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// FindImageMasks returns the mask labels of the image along with the encoded
+// masks, see the mask package for the format. The masks are not part of Image
+// as they are only needed when rendering them.
+func FindImageMasks(db *gorm.DB, imageID uuid.UUID) ([]string, []byte, error) {
+	var res struct {
+		MasksLabels pq.StringArray
+		Masks       []byte
+	}
+	if err := db.Table("image").
+		Select("masks_labels, masks").
+		Where("id = ?", imageID).
+		Take(&res).Error; err != nil {
+		return nil, nil, err
+	}
+	return res.MasksLabels, res.Masks, nil
+}
+
+// AllImageMasksForProject returns the encoded masks of every image of the
+// project that has any, by image ID.
+func AllImageMasksForProject(db *gorm.DB, projectID uuid.UUID) (map[uuid.UUID][]byte, error) {
+	var rows []struct {
+		ID    uuid.UUID
+		Masks []byte
+	}
+	if err := db.Table("image").
+		Select("id, masks").
+		Where("project_id = ? AND masks IS NOT NULL", projectID).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	masks := make(map[uuid.UUID][]byte, len(rows))
+	for _, r := range rows {
+		masks[r.ID] = r.Masks
+	}
+	return masks, nil
+}
+
+// ImageLabels is the output of the model for a single image.
+type ImageLabels struct {
+	Things      []string
+	Stuff       []string
+	MasksLabels []string
+
+	// Masks holds one mask per entry of MasksLabels, in the same order
+	Masks []byte
+
+	// Scores are optional, not every model reports them
+	Scores LabelScores
+}
+
+// SetImageLabels replaces the labels and masks of the image.
+func SetImageLabels(db *gorm.DB, imageID uuid.UUID, labels ImageLabels) error {
+	return db.Table("image").
+		Where("id = ?", imageID).
+		Updates(map[string]interface{}{
+			"labels_things": pq.StringArray(labels.Things),
+			"labels_stuff":  pq.StringArray(labels.Stuff),
+			"masks_labels":  pq.StringArray(labels.MasksLabels),
+			"masks":         labels.Masks,
+			"label_scores":  labels.Scores,
+			"updated_at":    time.Now(),
+		}).Error
+}
+
 func DeleteImageByID(db *gorm.DB, imageID uuid.UUID) error {
 	return db.Delete(&Image{}, "id = ?", imageID).Error
 }
+
+// DeleteImageVersion deletes the image only if it is still at the given
+// version, returning ErrVersionConflict otherwise.
+func DeleteImageVersion(db *gorm.DB, imageID uuid.UUID, version int) error {
+	res := db.Delete(&Image{}, "id = ? AND version = ?", imageID, version)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// AllLabelsForProject returns every distinct label found on the images of the
+// given project, as corrected by the user, sorted alphabetically.
+func AllLabelsForProject(db *gorm.DB, projectID uuid.UUID) ([]string, error) {
+	rows, err := db.Raw(`
+		SELECT DISTINCT label
+		FROM image, unnest(image.labels_all) AS label
+		WHERE project_id = ?
+		ORDER BY label`, projectID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := make([]string, 0)
+	for rows.Next() {
+		var l string
+		if err := rows.Scan(&l); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
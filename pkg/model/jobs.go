@@ -1,28 +1,114 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
 )
 
+// Job statuses. A job is created as queued, and becomes pending once the
+// scheduler decides there is room for it. From there the worker moves it
+// forward until it is either finished or failed.
+const (
+	JobStatusQueued        = "QUEUED"
+	JobStatusPendingLabels = "PENDING_LABELS"
+	JobStatusProcessing    = "PROCESSING"
+	JobStatusFinished      = "FINISHED"
+	JobStatusFailed        = "FAILED"
+)
+
 type Job struct {
-	ID        uuid.UUID
-	ProjectID uuid.UUID
-	Status    string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             uuid.UUID
+	ProjectID      uuid.UUID
+	Status         string
+	ResultImageURL *string
+	Progress       *int
+	Priority       int
+	Params         JobParams
+	Attempts       int
+	Error          *string
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 func (*Job) TableName() string {
 	return "jobs"
 }
 
-func CreateNewJob(db *gorm.DB, projectID uuid.UUID) (*Job, error) {
+// JobParams is a snapshot of the inputs of a job, taken when the job is
+// created, so later changes to the project do not change what the job
+// processed.
+type JobParams struct {
+	Keywords []string               `json:"keywords"`
+	Images   []JobImage             `json:"images"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// JobImage is an image processed by a job.
+type JobImage struct {
+	ID       uuid.UUID `json:"id"`
+	Checksum string    `json:"checksum,omitempty"`
+}
+
+// NewJobParams takes a snapshot of the current keywords and images of the
+// project.
+func NewJobParams(db *gorm.DB, project *Project, options map[string]interface{}) (JobParams, error) {
+	images, err := AllImagesForProject(db, project.ID)
+	if err != nil {
+		return JobParams{}, err
+	}
+
+	params := JobParams{
+		Keywords: append([]string{}, project.Keywords...),
+		Images:   make([]JobImage, len(images)),
+		Options:  options,
+	}
+	for i, img := range images {
+		params.Images[i] = JobImage{ID: img.ID}
+		if img.Checksum != nil {
+			params.Images[i].Checksum = *img.Checksum
+		}
+	}
+	return params, nil
+}
+
+func (p JobParams) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (p *JobParams) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		// jobs created before parameters were recorded
+		*p = JobParams{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("cannot scan %T into JobParams", src)
+	}
+}
+
+// CreateNewJob queues a new job for the given project, the job stays queued
+// until it is scheduled.
+func CreateNewJob(db *gorm.DB, projectID uuid.UUID, priority int, params JobParams) (*Job, error) {
 	j := Job{
 		ProjectID: projectID,
-		Status:    "PENDING_LABELS",
+		Status:    JobStatusQueued,
+		Priority:  priority,
+		Params:    params,
 	}
 	if err := db.Create(&j).Error; err != nil {
 		return nil, err
@@ -30,10 +116,238 @@ func CreateNewJob(db *gorm.DB, projectID uuid.UUID) (*Job, error) {
 	return &j, nil
 }
 
+// FindJobForProject returns the most recent job of the given project.
 func FindJobForProject(db *gorm.DB, projectID uuid.UUID) (*Job, error) {
 	var j Job
-	if err := db.Where("project_id = ?", projectID).Take(&j).Error; err != nil {
+	if err := db.Where("project_id = ?", projectID).
+		Order("created_at DESC").
+		Take(&j).Error; err != nil {
 		return nil, err
 	}
 	return &j, nil
 }
+
+// IsFinal reports whether the job is done, successfully or not.
+func (j *Job) IsFinal() bool {
+	return j.Status == JobStatusFinished || j.Status == JobStatusFailed
+}
+
+// FindStaleJobs returns the jobs in the given status that have not been
+// updated since before the given time.
+func FindStaleJobs(db *gorm.DB, status string, updatedBefore time.Time) ([]*Job, error) {
+	var j []*Job
+	if err := db.Where("status = ? AND updated_at < ?", status, updatedBefore).
+		Order("updated_at").
+		Find(&j).Error; err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// RequeueJob puts the job back in the pending status so a worker can pick it
+// up again. The job is only modified if it has not changed since it was
+// loaded, false is returned otherwise.
+func RequeueJob(db *gorm.DB, j *Job) (bool, error) {
+	return updateUnchangedJob(db, j, map[string]interface{}{
+		"status":     JobStatusPendingLabels,
+		"attempts":   j.Attempts + 1,
+		"progress":   nil,
+		"started_at": nil,
+	})
+}
+
+// FailJob marks the job as failed with the given reason. The job is only
+// modified if it has not changed since it was loaded, false is returned
+// otherwise.
+func FailJob(db *gorm.DB, j *Job, reason string) (bool, error) {
+	return updateUnchangedJob(db, j, map[string]interface{}{
+		"status": JobStatusFailed,
+		"error":  reason,
+	})
+}
+
+// SetJobProgress records the progress percentage reported by a worker. The
+// job is only modified if it has not changed since it was loaded, false is
+// returned otherwise.
+func SetJobProgress(db *gorm.DB, j *Job, progress int) (bool, error) {
+	return updateUnchangedJob(db, j, map[string]interface{}{
+		"progress": progress,
+	})
+}
+
+// FinishJob marks the job as finished. The job is only modified if it has not
+// changed since it was loaded, false is returned otherwise.
+func FinishJob(db *gorm.DB, j *Job, resultImageURL *string) (bool, error) {
+	return updateUnchangedJob(db, j, map[string]interface{}{
+		"status":           JobStatusFinished,
+		"progress":         100,
+		"result_image_url": resultImageURL,
+	})
+}
+
+func updateUnchangedJob(db *gorm.DB, j *Job, changes map[string]interface{}) (bool, error) {
+	changes["updated_at"] = time.Now()
+	res := db.Table("jobs").
+		Where("id = ? AND status = ? AND updated_at = ?", j.ID, j.Status, j.UpdatedAt).
+		Updates(changes)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, db.Where("id = ?", j.ID).Take(j).Error
+}
+
+// CountActiveJobs returns how many jobs are pending or being processed.
+func CountActiveJobs(db *gorm.DB) (int, error) {
+	var n int
+	err := db.Model(&Job{}).
+		Where("status IN (?)", []string{JobStatusPendingLabels, JobStatusProcessing}).
+		Count(&n).Error
+	return n, err
+}
+
+// PromoteNextQueuedJob moves the next queued job to pending. Users are served
+// fairly, the job picked belongs to the user with the fewest active jobs that
+// is still under its limit, and among that user's jobs the one with the
+// highest priority is picked first. The per user limit is the user's own
+// limit when set, perUserLimit otherwise, and zero means unlimited.
+// gorm.ErrRecordNotFound is returned when no job can be promoted.
+func PromoteNextQueuedJob(db *gorm.DB, perUserLimit int) (*Job, error) {
+	var j Job
+	err := db.Raw(`
+		WITH active AS (
+			SELECT p.user_record, count(*) AS n
+			FROM jobs j
+			JOIN project p ON p.id = j.project_id
+			WHERE j.status IN (?)
+			GROUP BY p.user_record
+		), next AS (
+			SELECT j.id
+			FROM jobs j
+			JOIN project p ON p.id = j.project_id
+			JOIN user_record u ON u.id = p.user_record
+			LEFT JOIN active a ON a.user_record = p.user_record
+			WHERE j.status = ?
+			  AND (coalesce(u.max_concurrent_jobs, ?) = 0 OR
+			       coalesce(a.n, 0) < coalesce(u.max_concurrent_jobs, ?))
+			ORDER BY coalesce(a.n, 0), j.priority DESC, j.created_at
+			LIMIT 1
+			FOR UPDATE OF j SKIP LOCKED
+		)
+		UPDATE jobs
+		SET status = ?, updated_at = ?
+		FROM next
+		WHERE jobs.id = next.id
+		RETURNING jobs.*`,
+		[]string{JobStatusPendingLabels, JobStatusProcessing},
+		JobStatusQueued,
+		perUserLimit,
+		perUserLimit,
+		JobStatusPendingLabels,
+		time.Now(),
+	).Scan(&j).Error
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// QueuePosition returns the 1 based position of a queued job, counting the
+// queued jobs that would be scheduled before it if all users were idle.
+func QueuePosition(db *gorm.DB, j *Job) (int, error) {
+	var n int
+	err := db.Model(&Job{}).
+		Where("status = ? AND (priority > ? OR (priority = ? AND created_at < ?))",
+			JobStatusQueued, j.Priority, j.Priority, j.CreatedAt).
+		Count(&n).Error
+	return n + 1, err
+}
+
+// ClaimNextJob hands the pending job with the highest priority to a worker,
+// moving it to processing. Jobs only become pending once the scheduler makes
+// room for them, so claiming honors the concurrency limits.
+// gorm.ErrRecordNotFound is returned when there is nothing to claim.
+func ClaimNextJob(db *gorm.DB) (*Job, error) {
+	var j Job
+	err := db.Raw(`
+		UPDATE jobs
+		SET status = ?, updated_at = ?
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE status = ?
+			ORDER BY priority DESC, created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		JobStatusProcessing,
+		time.Now(),
+		JobStatusPendingLabels,
+	).Scan(&j).Error
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func FindJobByID(db *gorm.DB, id uuid.UUID) (*Job, error) {
+	var j Job
+	if err := db.Where("id = ?", id).Take(&j).Error; err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// JobEvent is a status or progress change of a job. Events are recorded by the
+// database itself every time the jobs table changes.
+type JobEvent struct {
+	ID        int64
+	JobID     uuid.UUID
+	Status    string
+	Progress  *int
+	CreatedAt time.Time
+}
+
+// IsFinal reports whether the job will not change after this event.
+func (e *JobEvent) IsFinal() bool {
+	return e.Status == JobStatusFinished || e.Status == JobStatusFailed
+}
+
+// JobEventsSince returns the events of the given job with an ID greater than
+// afterID, oldest first.
+func JobEventsSince(db *gorm.DB, jobID uuid.UUID, afterID int64) ([]*JobEvent, error) {
+	var e []*JobEvent
+	if err := db.Where("job_id = ? AND id > ?", jobID, afterID).
+		Order("id").
+		Find(&e).Error; err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// AllJobEventsSince returns the events of every job with an ID greater than
+// afterID, oldest first.
+func AllJobEventsSince(db *gorm.DB, afterID int64) ([]*JobEvent, error) {
+	var e []*JobEvent
+	if err := db.Where("id > ?", afterID).Order("id").Find(&e).Error; err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// LastJobEventID returns the ID of the most recent job event, or 0 if there
+// are none.
+func LastJobEventID(db *gorm.DB) (int64, error) {
+	var res struct {
+		ID int64
+	}
+	if err := db.Table("job_event").
+		Select("coalesce(max(id), 0) AS id").
+		Scan(&res).Error; err != nil {
+		return 0, err
+	}
+	return res.ID, nil
+}
@@ -0,0 +1,188 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// LabelCategoryIDs returns the stable numeric ID of each one of the given
+// labels, creating the ones that do not have one yet.
+func LabelCategoryIDs(db *gorm.DB, labels []string) (map[string]int, error) {
+	ids := make(map[string]int, len(labels))
+	if len(labels) == 0 {
+		return ids, nil
+	}
+
+	if err := db.Exec(`
+		INSERT INTO label_category (name)
+		SELECT unnest(?::text[])
+		ON CONFLICT (name) DO NOTHING`, pq.StringArray(labels)).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ID   int
+		Name string
+	}
+	if err := db.Table("label_category").
+		Select("id, name").
+		Where("name IN (?)", labels).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, r := range rows {
+		ids[r.Name] = r.ID
+	}
+	return ids, nil
+}
+
+// LabelStat tells how often a label was found on the images of a project.
+type LabelStat struct {
+	Label string
+
+	// Occurrences counts every time the label was found, a label may be
+	// found more than once on the same image
+	Occurrences int
+
+	// Images counts the images the label was found on
+	Images int
+}
+
+// ProjectLabelStats returns the frequency of every label found on the images
+// of the project, most common first. Only the things and stuff of the images
+// are counted, as corrected by the user; mask labels repeat the things they
+// outline, so counting them as well would count those things twice.
+func ProjectLabelStats(db *gorm.DB, projectID uuid.UUID) ([]*LabelStat, error) {
+	var s []*LabelStat
+	if err := db.Raw(`
+		SELECT label, count(*) AS occurrences, count(DISTINCT image.id) AS images
+		FROM image, unnest(image.labels_all) AS label
+		WHERE image.project_id = ?
+		GROUP BY label
+		ORDER BY images DESC, occurrences DESC, label`, projectID).
+		Scan(&s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// KeywordMatch tells how many images of a project carry a label matching the
+// keyword. Keywords are matched case insensitively.
+type KeywordMatch struct {
+	Keyword string
+	Images  int
+}
+
+// ProjectKeywordMatches returns the number of images matching each one of the
+// given keywords, in the same order as the keywords.
+func ProjectKeywordMatches(db *gorm.DB, projectID uuid.UUID, keywords []string) ([]*KeywordMatch, error) {
+	var m []*KeywordMatch
+	if len(keywords) == 0 {
+		return m, nil
+	}
+	if err := db.Raw(`
+		SELECT k.keyword, count(image.id) AS images
+		FROM unnest(?::text[]) WITH ORDINALITY AS k(keyword, position)
+		LEFT JOIN image ON image.project_id = ? AND EXISTS(
+			SELECT 1 FROM unnest(image.labels_all) AS label WHERE lower(label) = lower(k.keyword)
+		)
+		GROUP BY k.keyword, k.position
+		ORDER BY k.position`, pq.StringArray(keywords), projectID).
+		Scan(&m).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ImagesMatchingNoKeyword returns the IDs of the images of the project that
+// carry no label matching any of the keywords, oldest first.
+func ImagesMatchingNoKeyword(db *gorm.DB, projectID uuid.UUID, keywords []string) ([]uuid.UUID, error) {
+	var rows []struct {
+		ID uuid.UUID
+	}
+	if err := db.Raw(`
+		SELECT image.id
+		FROM image
+		WHERE image.project_id = ? AND NOT EXISTS(
+			SELECT 1
+			FROM unnest(image.labels_all) AS label, unnest(?::text[]) AS keyword
+			WHERE lower(label) = lower(keyword)
+		)
+		ORDER BY image.created_at, image.id`, projectID, pq.StringArray(keywords)).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	return ids, nil
+}
+
+// CountImagesForProject returns how many images the project has.
+func CountImagesForProject(db *gorm.DB, projectID uuid.UUID) (int, error) {
+	var n int
+	err := db.Model(&Image{}).Where("project_id = ?", projectID).Count(&n).Error
+	return n, err
+}
+
+// Label categories that can be corrected by the user. Mask labels come with
+// a segmentation mask, so they cannot be edited on their own.
+const (
+	LabelCategoryThings = "things"
+	LabelCategoryStuff  = "stuff"
+)
+
+// ImageLabelEdit is a correction made by a user to the labels of an image.
+type ImageLabelEdit struct {
+	ID        uuid.UUID
+	ImageID   uuid.UUID
+	UserID    uuid.UUID `gorm:"column:user_record"`
+	Category  string
+	Added     pq.StringArray
+	Removed   pq.StringArray
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func CreateImageLabelEdit(db *gorm.DB, edit *ImageLabelEdit) error {
+	return db.Create(edit).Error
+}
+
+// AllLabelEditsForImage returns the label corrections of the image, oldest
+// first.
+func AllLabelEditsForImage(db *gorm.DB, imageID uuid.UUID) ([]*ImageLabelEdit, error) {
+	var e []*ImageLabelEdit
+	if err := db.Where("image_id = ?", imageID).
+		Order("created_at").
+		Find(&e).Error; err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetImageUserLabels saves the labels corrected by the user. The image must
+// still be at the version it was loaded with, otherwise ErrVersionConflict is
+// returned.
+func SetImageUserLabels(db *gorm.DB, image *Image, things, stuff []string) error {
+	res := db.Table("image").
+		Where("id = ? AND version = ?", image.ID, image.Version).
+		Updates(map[string]interface{}{
+			"user_labels_things": pq.StringArray(things),
+			"user_labels_stuff":  pq.StringArray(stuff),
+			"updated_at":         time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	image.Version++
+	return nil
+}
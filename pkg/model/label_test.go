@@ -0,0 +1,132 @@
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	gomigrate "github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+
+	"github.com/caquillo07/pyvinci-server/database"
+)
+
+// testDB connects to the database in PYVINCI_TEST_DATABASE and migrates it,
+// skipping the test when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("PYVINCI_TEST_DATABASE")
+	if dsn == "" {
+		t.Skip("PYVINCI_TEST_DATABASE is not set")
+	}
+
+	db, err := database.Open(database.Config{Driver: "postgres", ConnectionString: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := postgres.WithInstance(db.DB(), &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := gomigrate.NewWithDatabaseInstance("file://../../migrations", "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil && err != gomigrate.ErrNoChange {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newLabel returns a label no other test uses.
+func newLabel(name string) string {
+	return name + "-" + uuid.Must(uuid.NewV4()).String()[:8]
+}
+
+func TestLabelCategoryIDs(t *testing.T) {
+	db := testDB(t)
+	cat, dog, sky := newLabel("cat"), newLabel("dog"), newLabel("sky")
+
+	first, err := LabelCategoryIDs(db, []string{cat, dog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[cat] == 0 || first[dog] == 0 || first[cat] == first[dog] {
+		t.Fatalf("LabelCategoryIDs() = %v, want a distinct ID for %s and %s", first, cat, dog)
+	}
+
+	// the same labels keep their IDs whatever the order they are asked in or
+	// the labels they come with
+	second, err := LabelCategoryIDs(db, []string{sky, dog, cat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second[cat] != first[cat] || second[dog] != first[dog] {
+		t.Errorf("LabelCategoryIDs() = %v, want %s and %s kept from %v", second, cat, dog, first)
+	}
+	if second[sky] == 0 || second[sky] == first[cat] || second[sky] == first[dog] {
+		t.Errorf("LabelCategoryIDs() gave %s the ID %d, want a new one", sky, second[sky])
+	}
+
+	empty, err := LabelCategoryIDs(db, nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("LabelCategoryIDs(nil) = %v, %v, want no IDs", empty, err)
+	}
+}
+
+func TestProjectLabelStats(t *testing.T) {
+	db := testDB(t)
+	user := &User{Username: newLabel("stats")}
+	if err := CreateUser(db, user, "password"); err != nil {
+		t.Fatal(err)
+	}
+	project := &Project{UserID: user.ID, Name: "stats"}
+	if err := CreateProject(db, project); err != nil {
+		t.Fatal(err)
+	}
+
+	cat, dog, sky := newLabel("cat"), newLabel("dog"), newLabel("sky")
+	images := []ImageLabels{
+		// the mask of a thing does not count it once more
+		{Things: []string{cat, cat}, Stuff: []string{sky}, MasksLabels: []string{cat, cat}},
+		{Things: []string{cat, dog}, MasksLabels: []string{dog}},
+		{Things: []string{dog}, Stuff: []string{sky}},
+	}
+	var last *Image
+	for _, labels := range images {
+		last = &Image{ProjectID: project.ID, URL: "https://example.com/" + newLabel("image")}
+		if err := CreateImage(db, last); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetImageLabels(db, last.ID, labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the user removed dog from the last image, which stays off the stats
+	if err := db.Model(last).Update("user_labels_things", pq.StringArray{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ProjectLabelStats(db, project.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LabelStat{
+		{Label: cat, Occurrences: 3, Images: 2},
+		{Label: sky, Occurrences: 2, Images: 2},
+		{Label: dog, Occurrences: 1, Images: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("ProjectLabelStats() returned %d labels, want %d", len(stats), len(want))
+	}
+	for i, s := range stats {
+		if *s != want[i] {
+			t.Errorf("ProjectLabelStats()[%d] = %+v, want %+v", i, *s, want[i])
+		}
+	}
+}
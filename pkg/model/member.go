@@ -0,0 +1,150 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// Roles of the members of a project, from the most to the least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// ErrLastOwner is returned when removing or demoting the only owner of a
+// project, which would leave nobody able to manage it.
+var ErrLastOwner = errors.New("project must keep at least one owner")
+
+// RoleAllows reports whether role grants at least the permissions of
+// required.
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[required]
+}
+
+// IsRole reports whether role is one of the known roles.
+func IsRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+type ProjectMember struct {
+	ProjectID uuid.UUID `gorm:"primary_key"`
+	UserID    uuid.UUID `gorm:"column:user_record;primary_key"`
+	Role      string
+	InvitedBy *uuid.UUID
+
+	// Username is loaded along with the member, it is not a column
+	Username  string `gorm:"-"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func AddProjectMember(db *gorm.DB, m *ProjectMember) error {
+	return db.Create(m).Error
+}
+
+// FindProjectMember returns the membership of the user in the project.
+func FindProjectMember(db *gorm.DB, projectID, userID uuid.UUID) (*ProjectMember, error) {
+	var m ProjectMember
+	err := db.Where("project_id = ? AND user_record = ?", projectID, userID).Take(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// AllMembersForProject returns the members of the project along with their
+// usernames, oldest first.
+func AllMembersForProject(db *gorm.DB, projectID uuid.UUID) ([]*ProjectMember, error) {
+	type memberRow struct {
+		ProjectID  uuid.UUID
+		UserRecord uuid.UUID
+		Role       string
+		InvitedBy  *uuid.UUID
+		Username   string
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
+	var rows []*memberRow
+	err := db.Table("project_member").
+		Select("project_member.*, user_record.username").
+		Joins("JOIN user_record ON user_record.id = project_member.user_record").
+		Where("project_member.project_id = ?", projectID).
+		Order("project_member.created_at, user_record.username").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*ProjectMember, len(rows))
+	for i, r := range rows {
+		members[i] = &ProjectMember{
+			ProjectID: r.ProjectID,
+			UserID:    r.UserRecord,
+			Role:      r.Role,
+			InvitedBy: r.InvitedBy,
+			Username:  r.Username,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+		}
+	}
+	return members, nil
+}
+
+// SetProjectMemberRole changes the role of the member, making sure the
+// project keeps an owner.
+func SetProjectMemberRole(db *gorm.DB, m *ProjectMember, role string) error {
+	if m.Role == RoleOwner && role != RoleOwner {
+		if err := checkOtherOwners(db, m); err != nil {
+			return err
+		}
+	}
+
+	err := db.Model(m).Updates(map[string]interface{}{"role": role}).Error
+	if err != nil {
+		return err
+	}
+	m.Role = role
+	return nil
+}
+
+// RemoveProjectMember removes the member from the project, making sure the
+// project keeps an owner.
+func RemoveProjectMember(db *gorm.DB, m *ProjectMember) error {
+	if m.Role == RoleOwner {
+		if err := checkOtherOwners(db, m); err != nil {
+			return err
+		}
+	}
+	return db.Delete(m).Error
+}
+
+// checkOtherOwners returns ErrLastOwner unless the project has owners
+// besides m. The owners are locked so that two of them can not step down at
+// the same time, it must run inside a transaction.
+func checkOtherOwners(db *gorm.DB, m *ProjectMember) error {
+	var owners []*ProjectMember
+	err := db.Set("gorm:query_option", "FOR UPDATE").
+		Where("project_id = ? AND role = ?", m.ProjectID, RoleOwner).
+		Find(&owners).Error
+	if err != nil {
+		return err
+	}
+
+	for _, o := range owners {
+		if o.UserID != m.UserID {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrInvalidCursor is returned when a cursor can not be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points to the last row of a page, listings continue right after it.
+// Rows are ordered by creation time, ties are broken by id.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// String encodes the cursor into an opaque, URL safe string.
+func (c *Cursor) String() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a cursor previously encoded with Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ",", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.FromString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// Page selects a slice of a listing.
+type Page struct {
+	// Limit is the max number of rows returned.
+	Limit int
+
+	// After continues the listing after the given row, nil starts from the
+	// beginning.
+	After *Cursor
+
+	// Ascending lists the oldest rows first, by default newest are first.
+	Ascending bool
+}
+
+// apply adds the ordering, cursor and limit of the page to the query on the
+// given table. One extra row is loaded to know if there is a next page.
+func (p Page) apply(db *gorm.DB, table string) *gorm.DB {
+	dir, cmp := "DESC", "<"
+	if p.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+
+	if p.After != nil {
+		db = db.Where(
+			fmt.Sprintf("(%[1]s.created_at, %[1]s.id) %[2]s (?, ?)", table, cmp),
+			p.After.CreatedAt,
+			p.After.ID,
+		)
+	}
+	return db.
+		Order(fmt.Sprintf("%[1]s.created_at %[2]s, %[1]s.id %[2]s", table, dir)).
+		Limit(p.Limit + 1)
+}
+
+// nextCursor returns the cursor for the page following a page of n rows,
+// where last returns the creation time and id of the last row kept. It
+// returns nil when there are no more rows.
+func (p Page) nextCursor(n int, last func(i int) (time.Time, uuid.UUID)) *Cursor {
+	if n <= p.Limit {
+		return nil
+	}
+	createdAt, id := last(p.Limit - 1)
+	return &Cursor{CreatedAt: createdAt, ID: id}
+}
+
+// likePrefix escapes s to be used as a prefix in a LIKE pattern.
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -8,27 +9,103 @@ import (
 	"github.com/lib/pq"
 )
 
+// ErrVersionConflict is returned when a row changed since it was loaded.
+var ErrVersionConflict = errors.New("version conflict")
+
 type Project struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID `gorm:"column:user_record"`
-	Name      string
-	Keywords  pq.StringArray
+	ID       uuid.UUID
+	UserID   uuid.UUID `gorm:"column:user_record"`
+	Name     string
+	Keywords pq.StringArray
+
+	// Version is bumped by the database on every update
+	Version int `gorm:"default:1"`
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// CreateProject creates the project and makes its user the owner, it must
+// run inside a transaction.
 func CreateProject(db *gorm.DB, project *Project) error {
-	return db.Create(&project).Error
+	if err := db.Create(&project).Error; err != nil {
+		return err
+	}
+	return AddProjectMember(db, &ProjectMember{
+		ProjectID: project.ID,
+		UserID:    project.UserID,
+		Role:      RoleOwner,
+	})
 }
 
+// AllProjectsForUser returns the projects the user is a member of.
 func AllProjectsForUser(db *gorm.DB, userID uuid.UUID) ([]*Project, error) {
 	var p []*Project
-	if err := db.Where("user_record = ?", userID).Find(&p).Error; err != nil {
+	if err := db.Where(memberOfProject, userID).Find(&p).Error; err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
+// memberOfProject keeps the projects the user is a member of.
+const memberOfProject = "project.id IN (SELECT project_id FROM project_member WHERE user_record = ?)"
+
+// ProjectFilter narrows down the projects returned by ListProjectsForUser.
+// Zero values do not filter.
+type ProjectFilter struct {
+	NamePrefix    string
+	Keyword       string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	// JobStatus keeps the projects whose latest job is in the given status.
+	JobStatus string
+}
+
+// ListProjectsForUser returns a page of the projects the user is a member of
+// matching the filter, and the cursor of the next page, nil on the last one.
+func ListProjectsForUser(
+	db *gorm.DB,
+	userID uuid.UUID,
+	filter ProjectFilter,
+	page Page,
+) ([]*Project, *Cursor, error) {
+	q := db.Where(memberOfProject, userID)
+	if filter.NamePrefix != "" {
+		q = q.Where("project.name ILIKE ?", likePrefix(filter.NamePrefix))
+	}
+	if filter.Keyword != "" {
+		q = q.Where("? = ANY(project.keywords)", filter.Keyword)
+	}
+	if filter.CreatedAfter != nil {
+		q = q.Where("project.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		q = q.Where("project.created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.JobStatus != "" {
+		q = q.Where(`(
+			SELECT jobs.status FROM jobs
+			WHERE jobs.project_id = project.id
+			ORDER BY jobs.created_at DESC
+			LIMIT 1
+		) = ?`, filter.JobStatus)
+	}
+
+	var p []*Project
+	if err := page.apply(q, "project").Find(&p).Error; err != nil {
+		return nil, nil, err
+	}
+
+	next := page.nextCursor(len(p), func(i int) (time.Time, uuid.UUID) {
+		return p[i].CreatedAt, p[i].ID
+	})
+	if next != nil {
+		p = p[:page.Limit]
+	}
+	return p, next, nil
+}
+
 func FindProjectByID(db *gorm.DB, projectID uuid.UUID) (*Project, error) {
 	var p Project
 	if err := db.Where("id = ?", projectID).Take(&p).Error; err != nil {
@@ -41,6 +118,35 @@ func DeleteProjectByID(db *gorm.DB, projectID uuid.UUID) error {
 	return db.Delete(&Project{}, "id = ?", projectID).Error
 }
 
+// DeleteProjectVersion deletes the project only if it is still at the given
+// version, returning ErrVersionConflict otherwise.
+func DeleteProjectVersion(db *gorm.DB, projectID uuid.UUID, version int) error {
+	res := db.Delete(&Project{}, "id = ? AND version = ?", projectID, version)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// Update saves the editable fields of the project. The project must still be
+// at the version it was loaded with, otherwise ErrVersionConflict is
+// returned.
 func (p *Project) Update(db *gorm.DB) error {
-	return db.Save(p).Error
+	res := db.Model(p).
+		Where("version = ?", p.Version).
+		Updates(map[string]interface{}{
+			"name":     p.Name,
+			"keywords": p.Keywords,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	p.Version++
+	return nil
 }
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ShareLink grants read-only access to a project to anyone knowing its
+// token, no account needed.
+type ShareLink struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	CreatedBy uuid.UUID
+	Token     string
+
+	// Password is the bcrypt hash of the password of the link, nil when it
+	// needs none
+	Password *string
+
+	// FailedAttempts counts the wrong passwords sent in a row, the link is
+	// locked until LockedUntil once there are too many
+	FailedAttempts int
+	LockedUntil    *time.Time
+
+	ExpiresAt      *time.Time
+	RevokedAt      *time.Time
+	AccessCount    int64
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// IsActive reports whether the link still grants access.
+func (l *ShareLink) IsActive(now time.Time) bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
+
+// IsLocked reports whether the link refuses passwords for now.
+func (l *ShareLink) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+// VerifyPassword reports whether password opens the link, links without a
+// password accept anything.
+func (l *ShareLink) VerifyPassword(password string) bool {
+	if l.Password == nil {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(*l.Password), []byte(password)) == nil
+}
+
+func CreateShareLink(db *gorm.DB, l *ShareLink) error {
+	return db.Create(l).Error
+}
+
+// AllShareLinksForProject returns the links of the project, revoked and
+// expired ones included, newest first.
+func AllShareLinksForProject(db *gorm.DB, projectID uuid.UUID) ([]*ShareLink, error) {
+	var l []*ShareLink
+	if err := db.Where("project_id = ?", projectID).Order("created_at DESC").Find(&l).Error; err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func FindShareLinkByID(db *gorm.DB, id uuid.UUID) (*ShareLink, error) {
+	var l ShareLink
+	if err := db.Where("id = ?", id).Take(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// FindActiveShareLink returns the link with the given token, as long as it
+// was not revoked and did not expire.
+func FindActiveShareLink(db *gorm.DB, token string) (*ShareLink, error) {
+	var l ShareLink
+	err := db.Where(
+		"token = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)",
+		token,
+		time.Now(),
+	).Take(&l).Error
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// RevokeShareLink stops the link from granting access, links revoked
+// already are left as they are.
+func RevokeShareLink(db *gorm.DB, l *ShareLink) error {
+	if l.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	err := db.Model(l).Where("revoked_at IS NULL").Updates(map[string]interface{}{
+		"revoked_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	l.RevokedAt = &now
+	return nil
+}
+
+// RecordShareLinkAccess counts an access through the link. The link itself
+// did not change, so its update time is left alone.
+func RecordShareLinkAccess(db *gorm.DB, l *ShareLink) error {
+	now := time.Now()
+	return db.Model(l).UpdateColumns(map[string]interface{}{
+		"access_count":     gorm.Expr("access_count + 1"),
+		"last_accessed_at": now,
+	}).Error
+}
+
+// RecordShareLinkFailedAttempt counts a wrong password sent to the link. The
+// link is locked for the given duration once maxAttempts wrong passwords
+// were sent in a row, starting the count over.
+func RecordShareLinkFailedAttempt(db *gorm.DB, l *ShareLink, maxAttempts int, lockout time.Duration) error {
+	return db.Raw(`
+		UPDATE share_link
+		SET failed_attempts = CASE WHEN failed_attempts + 1 >= ? THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= ? THEN ? ELSE locked_until END
+		WHERE id = ?
+		RETURNING failed_attempts, locked_until`,
+		maxAttempts, maxAttempts, time.Now().Add(lockout), l.ID,
+	).Row().Scan(&l.FailedAttempts, &l.LockedUntil)
+}
+
+// ResetShareLinkFailedAttempts forgets the wrong passwords sent to the link
+// once the right one is.
+func ResetShareLinkFailedAttempts(db *gorm.DB, l *ShareLink) error {
+	if l.FailedAttempts == 0 {
+		return nil
+	}
+	if err := db.Model(l).UpdateColumn("failed_attempts", 0).Error; err != nil {
+		return err
+	}
+	l.FailedAttempts = 0
+	return nil
+}
@@ -9,10 +9,14 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID
-	Username  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID       uuid.UUID
+	Username string
+
+	// MaxConcurrentJobs overrides the configured number of jobs the user may
+	// have running at once.
+	MaxConcurrentJobs *int
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 }
 
 func (User) TableName() string {
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryFailed    = "FAILED"
+)
+
+type Webhook struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID `gorm:"column:user_record"`
+	ProjectID *uuid.UUID
+	URL       string
+	Secret    string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	WebhookID     uuid.UUID
+	JobID         uuid.UUID
+	Event         string
+	Payload       string
+	Status        string
+	Attempts      int
+	ResponseCode  *int
+	Error         *string
+	NextAttemptAt *time.Time
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func CreateWebhook(db *gorm.DB, w *Webhook) error {
+	return db.Create(w).Error
+}
+
+func AllWebhooksForUser(db *gorm.DB, userID uuid.UUID) ([]*Webhook, error) {
+	var w []*Webhook
+	if err := db.Where("user_record = ?", userID).Order("created_at").Find(&w).Error; err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ActiveWebhooksForProject returns the webhooks registered for the given
+// project, along with the ones registered for every project of its members.
+// Webhooks of users no longer members are left out.
+func ActiveWebhooksForProject(db *gorm.DB, project *Project) ([]*Webhook, error) {
+	var w []*Webhook
+	if err := db.Where(
+		`active AND (project_id = ? OR project_id IS NULL) AND user_record IN (
+			SELECT user_record FROM project_member WHERE project_id = ?
+		)`,
+		project.ID,
+		project.ID,
+	).Find(&w).Error; err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func FindWebhookByID(db *gorm.DB, id uuid.UUID) (*Webhook, error) {
+	var w Webhook
+	if err := db.Where("id = ?", id).Take(&w).Error; err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func DeleteWebhookByID(db *gorm.DB, id uuid.UUID) error {
+	return db.Delete(&Webhook{}, "id = ?", id).Error
+}
+
+// CreateWebhookDelivery queues a new delivery. Only one delivery is ever
+// created per webhook, job and event, so this is safe to call more than once;
+// false is returned if the delivery already existed.
+func CreateWebhookDelivery(db *gorm.DB, d *WebhookDelivery) (bool, error) {
+	now := time.Now()
+	d.Status = WebhookDeliveryPending
+	d.NextAttemptAt = &now
+	err := db.Set("gorm:insert_option", "ON CONFLICT (webhook_id, job_id, event) DO NOTHING").
+		Create(d).Error
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClaimDueWebhookDeliveries returns up to limit pending deliveries that are due
+// to be attempted. The claimed deliveries are pushed back by lease, so other
+// servers will not pick them up while they are being delivered.
+func ClaimDueWebhookDeliveries(db *gorm.DB, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	var d []*WebhookDelivery
+	now := time.Now()
+	err := db.Raw(`
+		UPDATE webhook_delivery
+		SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id
+			FROM webhook_delivery
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), WebhookDeliveryPending, now, limit).
+		Scan(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ClaimUnqueuedJobEvents returns up to limit final job events whose webhook
+// deliveries have not been queued yet, oldest first. The events stay locked
+// until tx ends, which should mark them as queued with MarkJobEventQueued.
+func ClaimUnqueuedJobEvents(tx *gorm.DB, limit int) ([]*JobEvent, error) {
+	var e []*JobEvent
+	err := tx.Raw(`
+		SELECT e.*
+		FROM webhook_job_event w
+		JOIN job_event e ON e.id = w.job_event_id
+		ORDER BY w.job_event_id
+		LIMIT ?
+		FOR UPDATE OF w SKIP LOCKED`, limit).
+		Scan(&e).Error
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// MarkJobEventQueued records the webhook deliveries of the event as queued.
+func MarkJobEventQueued(tx *gorm.DB, eventID int64) error {
+	return tx.Exec("DELETE FROM webhook_job_event WHERE job_event_id = ?", eventID).Error
+}
+
+func AllDeliveriesForWebhook(db *gorm.DB, webhookID uuid.UUID) ([]*WebhookDelivery, error) {
+	var d []*WebhookDelivery
+	if err := db.Where("webhook_id = ?", webhookID).
+		Order("created_at DESC").
+		Find(&d).Error; err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func FindWebhookDeliveryByID(db *gorm.DB, id uuid.UUID) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	if err := db.Where("id = ?", id).Take(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (d *WebhookDelivery) Update(db *gorm.DB) error {
+	return db.Save(d).Error
+}
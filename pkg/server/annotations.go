@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/annotation"
+	"github.com/caquillo07/pyvinci-server/pkg/mask"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+// getProjectAnnotations exports the labels and masks of the project images as
+// COCO JSON (the default) or as a zip of Pascal VOC XML files.
+func (s *Server) getProjectAnnotations(c *fiber.Ctx) error {
+	format := c.Query("format", "coco")
+	err := validate.New().
+		Field("format", format, validate.OneOf("coco", "voc")).
+		Err()
+	if err != nil {
+		return err
+	}
+
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	dataset, err := s.projectDataset(project)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fileName := fmt.Sprintf("%s-%s", project.ID.String(), format)
+	switch format {
+	case "coco":
+		if err := annotation.WriteCOCO(&buf, dataset); err != nil {
+			return err
+		}
+		c.Set("Content-Type", "application/json")
+		fileName += ".json"
+	case "voc":
+		if err := annotation.WriteVOC(&buf, dataset); err != nil {
+			return err
+		}
+		c.Set("Content-Type", "application/zip")
+		fileName += ".zip"
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.SendBytes(buf.Bytes())
+	return nil
+}
+
+// projectDataset gathers the images, labels and masks of the project. Images
+// are numbered in the order they were uploaded, and every label gets its
+// stable category ID.
+func (s *Server) projectDataset(project *model.Project) (*annotation.Dataset, error) {
+	images, err := model.AllImagesForProject(s.db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].CreatedAt.Equal(images[j].CreatedAt) {
+			return images[i].ID.String() < images[j].ID.String()
+		}
+		return images[i].CreatedAt.Before(images[j].CreatedAt)
+	})
+
+	encodedMasks, err := model.AllImageMasksForProject(s.db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	supercategories := map[string]string{}
+	for _, img := range images {
+		for _, l := range img.MasksLabels {
+			supercategories[l] = annotation.SupercategoryOther
+		}
+		for _, l := range img.EffectiveStuff() {
+			supercategories[l] = annotation.SupercategoryStuff
+		}
+		for _, l := range img.EffectiveThings() {
+			supercategories[l] = annotation.SupercategoryThing
+		}
+	}
+
+	labels := make([]string, 0, len(supercategories))
+	for l := range supercategories {
+		labels = append(labels, l)
+	}
+	categoryIDs, err := model.LabelCategoryIDs(s.db, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := &annotation.Dataset{
+		Name:       project.Name,
+		CreatedAt:  project.CreatedAt,
+		Categories: make([]annotation.Category, 0, len(labels)),
+		Images:     make([]annotation.Image, len(images)),
+	}
+	for _, l := range labels {
+		dataset.Categories = append(dataset.Categories, annotation.Category{
+			ID:            categoryIDs[l],
+			Name:          l,
+			Supercategory: supercategories[l],
+		})
+	}
+	sort.Slice(dataset.Categories, func(i, j int) bool {
+		return dataset.Categories[i].ID < dataset.Categories[j].ID
+	})
+
+	for i, img := range images {
+		var masks []*mask.Mask
+		if data, ok := encodedMasks[img.ID]; ok {
+			masks, err = mask.Decode(data)
+			if err != nil {
+				return nil, fmt.Errorf("image %s: %w", img.ID, err)
+			}
+			if len(masks) != len(img.MasksLabels) {
+				return nil, fmt.Errorf("image %s: masks do not match its labels", img.ID)
+			}
+		}
+
+		// the image size is only known for images uploaded after it was
+		// recorded, the masks are the next best thing.
+		annotated := annotation.Image{
+			ID:       i + 1,
+			FileName: path.Base(img.URL),
+			URL:      img.URL,
+			Objects:  make([]annotation.Object, 0, len(masks)),
+		}
+		if img.Width != nil && img.Height != nil {
+			annotated.Width, annotated.Height = *img.Width, *img.Height
+		} else if len(masks) > 0 {
+			annotated.Width, annotated.Height = masks[0].Width, masks[0].Height
+		}
+
+		// only the runs of the masks are kept, so a single image is ever
+		// decoded at a time
+		for j, m := range masks {
+			annotated.Objects = append(annotated.Objects, annotation.NewObject(
+				categoryIDs[img.MasksLabels[j]], m, annotated.Width, annotated.Height,
+			))
+		}
+		dataset.Images[i] = annotated
+	}
+	return dataset, nil
+}
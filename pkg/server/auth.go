@@ -1,34 +1,61 @@
 package server
 
 import (
+	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gofiber/fiber"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
 	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
 )
 
-func (s *Server) register(c *fiber.Ctx) error {
-	type RegisterRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
-	type RegisterResponse struct {
-		ID        string    `json:"id"`
-		Username  string    `json:"username"`
-		CreateAt  time.Time `json:"createAt"`
-		UpdatedAt time.Time `json:"updatedAt"`
-	}
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 50
+	minPasswordLength = 8
+
+	// bcrypt ignores anything past 72 bytes
+	maxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
 
-	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
+type registerRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type registerResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	CreateAt  time.Time `json:"createAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (s *Server) register(c *fiber.Ctx) error {
+	var req registerRequest
+	if err := parseBody(c, &req); err != nil {
 		return err
 	}
 
-	if req.Username == "" || req.Password == "" {
-		return newValidationError("both username and password are required")
+	err := validate.New().
+		Field("username", req.Username,
+			validate.Required(),
+			validate.Length(minUsernameLength, maxUsernameLength),
+			validate.Match(usernamePattern, "letters, digits, dots, dashes or underscores"),
+		).
+		Field("password", req.Password,
+			validate.Required(),
+			validate.Length(minPasswordLength, maxPasswordLength),
+		).
+		Err()
+	if err != nil {
+		return err
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
@@ -44,7 +71,7 @@ func (s *Server) register(c *fiber.Ctx) error {
 		return err
 	}
 
-	return c.Status(201).JSON(&RegisterResponse{
+	return c.Status(201).JSON(&registerResponse{
 		ID:        user.ID.String(),
 		Username:  user.Username,
 		CreateAt:  user.CreatedAt,
@@ -52,27 +79,32 @@ func (s *Server) register(c *fiber.Ctx) error {
 	})
 }
 
-func (s *Server) login(c *fiber.Ctx) error {
-	type LoginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
-	type LoginResponse struct {
-		ID        string    `json:"id"`
-		Username  string    `json:"username"`
-		CreateAt  time.Time `json:"createAt"`
-		UpdatedAt time.Time `json:"updatedAt"`
-		Token     string    `json:"token"`
-		ExpireAt  int64     `json:"expireAt"`
-	}
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
 
-	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
+type loginResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	CreateAt  time.Time `json:"createAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Token     string    `json:"token"`
+	ExpireAt  int64     `json:"expireAt"`
+}
+
+func (s *Server) login(c *fiber.Ctx) error {
+	var req loginRequest
+	if err := parseBody(c, &req); err != nil {
 		return err
 	}
 
-	if req.Username == "" || req.Password == "" {
-		return newValidationError("both username and password are required")
+	err := validate.New().
+		Field("username", req.Username, validate.Required()).
+		Field("password", req.Password, validate.Required()).
+		Err()
+	if err != nil {
+		return err
 	}
 
 	user, err := model.FindUserByUsername(s.db, req.Username)
@@ -85,7 +117,11 @@ func (s *Server) login(c *fiber.Ctx) error {
 		return err
 	}
 	if !valid {
-		return newNotFoundError("user with give username and pass not found")
+		return apierror.New(
+			http.StatusNotFound,
+			apierror.CodeInvalidCredentials,
+			"user with give username and pass not found",
+		)
 	}
 
 	token := jwt.New(jwt.SigningMethodHS256)
@@ -111,7 +147,7 @@ func (s *Server) login(c *fiber.Ctx) error {
 		return err
 	}
 
-	return c.JSON(&LoginResponse{
+	return c.JSON(&loginResponse{
 		ID:        user.ID.String(),
 		Username:  user.Username,
 		CreateAt:  user.CreatedAt,
@@ -1,35 +1,54 @@
 package server
 
-type PublicError interface {
-	PublicError() string
-	Code() int
-}
-
-type publicError struct {
-	msg  string
-	code int
-}
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
 
-func newPublicError(msg string, code int) error {
-	return publicError{msg: msg, code: code}
-}
+	"github.com/gofiber/fiber"
+	"github.com/jinzhu/gorm"
 
-func (e publicError) Error() string {
-	return e.msg
-}
-
-func (e publicError) Code() int {
-	return e.code
-}
-
-func (e publicError) PublicError() string {
-	return e.Error()
-}
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+)
 
 func newValidationError(msg string) error {
-	return publicError{msg: msg, code: 400}
-}
-
-func newNotFoundError(msg string) error {
-	return publicError{msg: msg, code: 404}
+	return apierror.Validation(msg)
+}
+
+// newNotFoundError returns the error for a missing resource, with the code
+// <resource>_not_found.
+func newNotFoundError(resource string) error {
+	return apierror.NotFound(resource)
+}
+
+// notFound gives a record not found error the code of the missing
+// resource, any other error is returned as is.
+func notFound(err error, resource string) error {
+	if gorm.IsRecordNotFoundError(err) {
+		return apierror.NotFound(resource).Wrap(err)
+	}
+	return err
+}
+
+// parseBody decodes the JSON body of the request into out.
+func parseBody(c *fiber.Ctx, out interface{}) error {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return apierror.New(
+			http.StatusBadRequest,
+			apierror.CodeUnsupportedContentType,
+			"Content-Type: application/json header is required",
+		)
+	}
+	return decodeJSON(c.Fasthttp.Request.Body(), out)
+}
+
+func decodeJSON(body []byte, out interface{}) error {
+	if err := json.Unmarshal(body, out); err != nil {
+		return apierror.New(
+			http.StatusBadRequest,
+			apierror.CodeInvalidJSON,
+			"request body is not valid JSON",
+		).Wrap(err)
+	}
+	return nil
 }
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+)
+
+// entityTag returns the ETag of a resource representation. It starts with
+// the version of the row, which is what If-Match is checked against, and
+// ends with a digest of the body, so data derived from other tables, like
+// the job of a project, also changes it.
+func entityTag(version int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%s"`, version, hex.EncodeToString(sum[:8]))
+}
+
+// jsonWithETag sends v as JSON along with its ETag.
+func jsonWithETag(c *fiber.Ctx, version int, v interface{}) error {
+	if err := c.JSON(v); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, entityTag(version, c.Fasthttp.Response.Body()))
+	return nil
+}
+
+// conditionalJSON is like jsonWithETag, but when the client already has this
+// representation, as told by If-None-Match, a 304 without body is sent
+// instead. Only meant for GET requests.
+func conditionalJSON(c *fiber.Ctx, version int, v interface{}) error {
+	if err := jsonWithETag(c, version, v); err != nil {
+		return err
+	}
+
+	// If-None-Match uses the weak comparison, so W/ prefixes are ignored
+	tag := string(c.Fasthttp.Response.Header.Peek(fiber.HeaderETag))
+	for _, t := range splitETags(c.Get(fiber.HeaderIfNoneMatch)) {
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			c.Fasthttp.Response.ResetBody()
+			c.Status(http.StatusNotModified)
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkIfMatch verifies the If-Match precondition against the current
+// version of a resource. A missing header always passes.
+func checkIfMatch(c *fiber.Ctx, version int) error {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return nil
+	}
+
+	// If-Match uses the strong comparison, so weak tags never match
+	for _, t := range splitETags(header) {
+		if t == "*" {
+			return nil
+		}
+		if v, ok := etagVersion(t); ok && v == version {
+			return nil
+		}
+	}
+	return errPreconditionFailed
+}
+
+var errPreconditionFailed = apierror.New(
+	http.StatusPreconditionFailed,
+	apierror.CodePreconditionFailed,
+	"resource was modified, fetch it again and retry",
+)
+
+// etagVersion returns the row version of an ETag created by entityTag.
+func etagVersion(tag string) (int, bool) {
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		return 0, false
+	}
+
+	tag = strings.Trim(tag, `"`)
+	if i := strings.IndexByte(tag, '-'); i != -1 {
+		tag = tag[:i]
+	}
+
+	v, err := strconv.Atoi(tag)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+func splitETags(header string) []string {
+	var tags []string
+	for _, t := range strings.Split(header, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
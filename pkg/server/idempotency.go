@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencySweepInterval = time.Hour
+)
+
+// idempotent makes POST routes safe to retry. The response to the first
+// request carrying an Idempotency-Key header is stored per user and key,
+// and replayed as is for every retry. Reusing a key for a different request
+// is rejected. Requests without the header are not affected.
+func (s *Server) idempotent() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		key := c.Get(headerIdempotencyKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if err := s.handleIdempotent(c, key); err != nil {
+			c.Next(err)
+		}
+	}
+}
+
+func (s *Server) handleIdempotent(c *fiber.Ctx, key string) error {
+	err := validate.New().
+		Field(headerIdempotencyKey, key, validate.Length(1, maxIdempotencyKeyLength)).
+		Err()
+	if err != nil {
+		return err
+	}
+
+	userID, err := getUserID(c)
+	if err != nil {
+		return newValidationError("valid user_id is required")
+	}
+
+	fingerprint, err := requestFingerprint(c)
+	if err != nil {
+		return err
+	}
+
+	reserved := &model.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(s.config.REST.IdempotencyKeyTTL),
+	}
+	ok, err := model.ReserveIdempotencyKey(s.db, reserved)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		existing, err := model.FindIdempotencyKey(s.db, userID, key)
+		if err != nil {
+			return err
+		}
+		return replayIdempotent(c, existing, fingerprint)
+	}
+
+	// the key is released unless the response gets stored, whether the
+	// handler failed, panicked or the response could not be saved, so the
+	// request can be retried
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		if err := model.ReleaseIdempotencyKey(s.db, reserved); err != nil {
+			zap.L().Error("failed to release idempotency key", zap.Error(err))
+		}
+	}()
+
+	c.Next()
+
+	// server errors are not stored so the request can be retried
+	status := c.Fasthttp.Response.StatusCode()
+	if status >= http.StatusInternalServerError {
+		return nil
+	}
+
+	body := append([]byte(nil), c.Fasthttp.Response.Body()...)
+	contentType := string(c.Fasthttp.Response.Header.ContentType())
+	if err := model.CompleteIdempotencyKey(s.db, reserved, status, contentType, body); err != nil {
+		zap.L().Error("failed to store idempotent response", zap.Error(err))
+		return nil
+	}
+	stored = true
+	return nil
+}
+
+// replayIdempotent sends the stored response of a key.
+func replayIdempotent(c *fiber.Ctx, k *model.IdempotencyKey, fingerprint string) error {
+	if k.Fingerprint != fingerprint {
+		return apierror.New(
+			http.StatusUnprocessableEntity,
+			apierror.CodeIdempotencyKeyReused,
+			"Idempotency-Key was already used for a different request",
+		)
+	}
+
+	if !k.IsCompleted() {
+		return apierror.New(
+			http.StatusConflict,
+			apierror.CodeIdempotencyKeyInUse,
+			"a request with this Idempotency-Key is still being processed",
+		)
+	}
+
+	c.Set(headerIdempotentReplayed, "true")
+	c.Status(*k.StatusCode)
+	if k.ContentType != nil {
+		c.Fasthttp.Response.Header.SetContentType(*k.ContentType)
+	}
+	c.Fasthttp.Response.SetBody(k.ResponseBody)
+	return nil
+}
+
+// requestFingerprint hashes the method, path and body of the request.
+// Multipart bodies are hashed by their fields and file contents, because
+// clients pick a new boundary on every attempt.
+func requestFingerprint(c *fiber.Ctx) (string, error) {
+	h := sha256.New()
+	_, _ = io.WriteString(h, c.Method()+" "+c.Path()+"\n")
+
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		_, _ = h.Write(c.Fasthttp.Request.Body())
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", newValidationError("invalid multipart form")
+	}
+
+	for _, name := range sortedKeys(form.Value) {
+		for _, v := range form.Value[name] {
+			_, _ = io.WriteString(h, "field "+name+"="+v+"\n")
+		}
+	}
+
+	fileNames := make([]string, 0, len(form.File))
+	for name := range form.File {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+	for _, name := range fileNames {
+		for _, fh := range form.File[name] {
+			_, _ = io.WriteString(h, "file "+name+"="+fh.Filename+"\n")
+			f, err := fh.Open()
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(h, f)
+			_ = f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sweepIdempotencyKeys periodically deletes the expired idempotency keys.
+func (s *Server) sweepIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(idempotencySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := model.DeleteExpiredIdempotencyKeys(s.db)
+			if err != nil {
+				zap.L().Error("failed to delete expired idempotency keys", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				zap.L().Info("deleted expired idempotency keys", zap.Int64("count", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+// maxSearchLabels bounds the labels of a single search.
+const maxSearchLabels = 20
+
+type searchImagesResponse struct {
+	Images     []*httpImage `json:"images"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+// searchImages finds the images across all the user's projects carrying the
+// labels given in the repeatable label query parameter. With match=all (the
+// default) images must carry every label, with match=any a single one is
+// enough. Images are paginated like every other listing.
+func (s *Server) searchImages(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return newValidationError("valid user_id is required")
+	}
+
+	var labels []string
+	for _, l := range c.Fasthttp.QueryArgs().PeekMulti("label") {
+		if len(l) > 0 {
+			labels = append(labels, string(l))
+		}
+	}
+	match := c.Query("match", "all")
+
+	v := validate.New().
+		Field("label", labels, validate.Required(), validate.Items(1, maxSearchLabels)).
+		Field("match", match, validate.OneOf("all", "any"))
+	page := parsePage(c, v)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	user, err := model.FindUserByID(s.db, userID)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	images, next, err := model.SearchImagesForUser(s.db, user.ID, labels, match == "all", page)
+	if err != nil {
+		return err
+	}
+
+	res := searchImagesResponse{NextCursor: nextCursorString(next)}
+	res.Images = make([]*httpImage, len(images))
+	for i, img := range images {
+		res.Images[i] = imageHTTPStruct(img)
+	}
+	return c.JSON(res)
+}
@@ -0,0 +1,337 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/gofrs/uuid"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// bounds of the priority a job can be started with
+const (
+	minJobPriority = 0
+	maxJobPriority = 10
+)
+
+type httpJobParams struct {
+	Keywords []string               `json:"keywords"`
+	Images   []httpJobImage         `json:"images"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type httpJobImage struct {
+	ID       string `json:"id"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type httpJob struct {
+	ID             string         `json:"id"`
+	ProjectID      string         `json:"projectId"`
+	Status         string         `json:"status"`
+	Progress       *int           `json:"progress,omitempty"`
+	Priority       int            `json:"priority"`
+	Params         *httpJobParams `json:"params"`
+	QueuePosition  int            `json:"queuePosition,omitempty"`
+	Attempts       int            `json:"attempts"`
+	Error          string         `json:"error,omitempty"`
+	ResultImageURL string         `json:"resultImageUrl,omitempty"`
+	QueuedAt       time.Time      `json:"queuedAt"`
+	StartedAt      *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time     `json:"finishedAt,omitempty"`
+}
+
+func jobHTTPStruct(j *model.Job) *httpJob {
+	res := &httpJob{
+		ID:         j.ID.String(),
+		ProjectID:  j.ProjectID.String(),
+		Status:     j.Status,
+		Progress:   j.Progress,
+		Priority:   j.Priority,
+		Params:     jobParamsHTTPStruct(j.Params),
+		Attempts:   j.Attempts,
+		QueuedAt:   j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+	if j.ResultImageURL != nil {
+		res.ResultImageURL = *j.ResultImageURL
+	}
+	if j.Error != nil {
+		res.Error = *j.Error
+	}
+	return res
+}
+
+func jobParamsHTTPStruct(p model.JobParams) *httpJobParams {
+	res := &httpJobParams{
+		Keywords: p.Keywords,
+		Images:   make([]httpJobImage, len(p.Images)),
+		Options:  p.Options,
+	}
+	if res.Keywords == nil {
+		res.Keywords = []string{}
+	}
+	for i, img := range p.Images {
+		res.Images[i] = httpJobImage{
+			ID:       img.ID.String(),
+			Checksum: img.Checksum,
+		}
+	}
+	return res
+}
+
+type getJobResponse struct {
+	Job *httpJob `json:"job"`
+}
+
+func (s *Server) getJob(c *fiber.Ctx) error {
+	jobID, err := uuid.FromString(c.Params("job_id"))
+	if err != nil {
+		return newValidationError("valid job_id is required")
+	}
+
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	job, err := model.FindJobByID(s.db, jobID)
+	if err != nil {
+		return notFound(err, "job")
+	}
+
+	if job.ProjectID != project.ID {
+		return newNotFoundError("job")
+	}
+
+	res := jobHTTPStruct(job)
+	if job.Status == model.JobStatusQueued {
+		res.QueuePosition, err = model.QueuePosition(s.db, job)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(getJobResponse{
+		Job: res,
+	})
+}
+
+type getJobResultResponse struct {
+	Job    *httpJob     `json:"job"`
+	Labels []string     `json:"labels"`
+	Images []*httpImage `json:"images"`
+}
+
+// getJobResult returns the output of a job, the rendered result image along
+// with the labels found on each one of the project images.
+func (s *Server) getJobResult(c *fiber.Ctx) error {
+	jobID, err := uuid.FromString(c.Params("job_id"))
+	if err != nil {
+		return newValidationError("valid job_id is required")
+	}
+
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	job, err := model.FindJobByID(s.db, jobID)
+	if err != nil {
+		return notFound(err, "job")
+	}
+
+	if job.ProjectID != project.ID {
+		return newNotFoundError("job")
+	}
+
+	labels, err := model.AllLabelsForProject(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	images, err := model.AllImagesForProject(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	httpImages := make([]*httpImage, len(images))
+	for i, img := range images {
+		httpImages[i] = imageHTTPStruct(img)
+	}
+
+	return c.JSON(getJobResultResponse{
+		Job:    jobHTTPStruct(job),
+		Labels: labels,
+		Images: httpImages,
+	})
+}
+
+const (
+	// how long clients should wait before reconnecting to an event stream
+	jobEventsRetryInterval = 3 * time.Second
+
+	// how often a comment is sent down an idle event stream so proxies and
+	// clients do not consider the connection dead. The stored events are
+	// checked again every time, in case a notification was missed.
+	jobEventsKeepAliveInterval = 15 * time.Second
+)
+
+type httpJobEvent struct {
+	JobID     string    `json:"jobId"`
+	Status    string    `json:"status"`
+	Progress  *int      `json:"progress,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// streamJobEvents is a Server-Sent Events stream of the status and progress
+// changes of a job. Every event carries the ID of the stored job event, so
+// clients reconnecting with a Last-Event-ID header pick up where they left
+// off. The stream is closed once the job is finished or failed, and clients
+// reconnecting after that get a 204 telling them to stop.
+func (s *Server) streamJobEvents(c *fiber.Ctx) error {
+	jobID, err := uuid.FromString(c.Params("job_id"))
+	if err != nil {
+		return newValidationError("valid job_id is required")
+	}
+
+	var lastEventID int64
+	if v := c.Get("Last-Event-ID"); v != "" {
+		lastEventID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return newValidationError("valid Last-Event-ID is required")
+		}
+	}
+
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	job, err := model.FindJobByID(s.db, jobID)
+	if err != nil {
+		return notFound(err, "job")
+	}
+
+	if job.ProjectID != project.ID {
+		return newNotFoundError("job")
+	}
+
+	if job.IsFinal() {
+		pending, err := model.JobEventsSince(s.db, job.ID, lastEventID)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			c.Status(http.StatusNoContent)
+			return nil
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Fasthttp.SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := s.writeJobEvents(w, job.ID, lastEventID); err != nil {
+			zap.L().Debug(
+				"job event stream closed",
+				zap.String("job_id", job.ID.String()),
+				zap.Error(err),
+			)
+		}
+	})
+	return nil
+}
+
+// writeJobEvents writes the events of the given job to w until the job is
+// done or the client goes away, in which case flushing fails.
+func (s *Server) writeJobEvents(w *bufio.Writer, jobID uuid.UUID, lastEventID int64) error {
+	sub := s.events.Subscribe()
+	defer sub.Close()
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", jobEventsRetryInterval.Milliseconds()); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	keepAlive := time.NewTicker(jobEventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		events, err := model.JobEventsSince(s.db, jobID, lastEventID)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := writeJobEvent(w, e); err != nil {
+				return err
+			}
+			lastEventID = e.ID
+		}
+		if len(events) > 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if events[len(events)-1].IsFinal() {
+				return nil
+			}
+		}
+
+		// jobs finished before events were recorded have no final event
+		job, err := model.FindJobByID(s.db, jobID)
+		if err != nil {
+			return err
+		}
+		if job.IsFinal() {
+			return nil
+		}
+
+	wait:
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					return nil
+				}
+				if e.Job != nil && e.Job.JobID == jobID {
+					break wait
+				}
+			case <-keepAlive.C:
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return err
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				break wait
+			}
+		}
+	}
+}
+
+func writeJobEvent(w *bufio.Writer, e *model.JobEvent) error {
+	data, err := json.Marshal(httpJobEvent{
+		JobID:     e.JobID.String(),
+		Status:    e.Status,
+		Progress:  e.Progress,
+		CreatedAt: e.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.ID, data)
+	return err
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+type httpImageLabelEdit struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Category  string    `json:"category"`
+	Added     []string  `json:"added"`
+	Removed   []string  `json:"removed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func imageLabelEditHTTPStruct(e *model.ImageLabelEdit) *httpImageLabelEdit {
+	return &httpImageLabelEdit{
+		ID:        e.ID.String(),
+		UserID:    e.UserID.String(),
+		Category:  e.Category,
+		Added:     e.Added,
+		Removed:   e.Removed,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+type httpImageLabelsHistory struct {
+	Original  *httpImageLabels      `json:"original"`
+	Effective *httpImageLabels      `json:"effective"`
+	Edits     []*httpImageLabelEdit `json:"edits"`
+}
+
+// getImageLabels returns the labels found by the model, the labels after the
+// user corrections, and the history of those corrections.
+func (s *Server) getImageLabels(c *fiber.Ctx) error {
+	_, image, err := s.findUserProjectImage(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.imageLabelsHistory(image)
+	if err != nil {
+		return err
+	}
+	return c.JSON(res)
+}
+
+type patchImageLabelsRequest struct {
+	Things *labelEditRequest `json:"things"`
+	Stuff  *labelEditRequest `json:"stuff"`
+}
+
+// patchImageLabels adds or removes labels of the things and stuff categories
+// of an image. The model output is left untouched, the corrections are
+// stored apart and recorded in the image history.
+func (s *Server) patchImageLabels(c *fiber.Ctx) error {
+	user, image, err := s.findUserProjectImage(c, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, image.Version); err != nil {
+		return err
+	}
+
+	var req patchImageLabelsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if req.Things == nil && req.Stuff == nil {
+		return newValidationError("at least one of things or stuff is required")
+	}
+
+	v := validate.New()
+	validateLabelEdit(v, "things", req.Things)
+	validateLabelEdit(v, "stuff", req.Stuff)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	var edits []*model.ImageLabelEdit
+	things, stuff := image.UserLabelsThings, image.UserLabelsStuff
+	// categories left as they are keep following the model, so their user
+	// labels are only replaced when the edit changed something
+	if req.Things != nil {
+		labels, edit := applyLabelEdit(image.EffectiveThings(), req.Things.Add, req.Things.Remove)
+		if edit != nil {
+			things = labels
+			edit.Category = model.LabelCategoryThings
+			edits = append(edits, edit)
+		}
+	}
+	if req.Stuff != nil {
+		labels, edit := applyLabelEdit(image.EffectiveStuff(), req.Stuff.Add, req.Stuff.Remove)
+		if edit != nil {
+			stuff = labels
+			edit.Category = model.LabelCategoryStuff
+			edits = append(edits, edit)
+		}
+	}
+
+	if len(edits) > 0 {
+		err = database.Transact(c.Context(), s.db, func(ctx context.Context, tx *gorm.DB) error {
+			if err := model.SetImageUserLabels(tx, image, things, stuff); err != nil {
+				return err
+			}
+			for _, edit := range edits {
+				edit.ImageID = image.ID
+				edit.UserID = user.ID
+				if err := model.CreateImageLabelEdit(tx, edit); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		image, err = model.FindImageByID(s.db, image.ID)
+		if err != nil {
+			return notFound(err, "image")
+		}
+	}
+
+	res, err := s.imageLabelsHistory(image)
+	if err != nil {
+		return err
+	}
+	return jsonWithETag(c, image.Version, res)
+}
+
+const (
+	maxLabelLength = 100
+	maxLabelEdits  = 100
+)
+
+// labelEditRequest lists the labels to add to and remove from a category.
+type labelEditRequest struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// validateLabelEdit checks the labels added to and removed from a category.
+func validateLabelEdit(v *validate.Validator, category string, edit *labelEditRequest) {
+	if edit == nil {
+		return
+	}
+
+	v.Field(category+".add", edit.Add, validate.Items(0, maxLabelEdits))
+	v.Each(category+".add", edit.Add, validate.Required(), validate.Length(1, maxLabelLength))
+	v.Field(category+".remove", edit.Remove, validate.Items(0, maxLabelEdits))
+	v.Each(category+".remove", edit.Remove, validate.Required(), validate.Length(1, maxLabelLength))
+
+	for i, l := range edit.Add {
+		if hasLabel(edit.Remove, l) {
+			v.AddError(fmt.Sprintf("%s.add[%d]", category, i), "can not be added and removed")
+		}
+	}
+}
+
+// applyLabelEdit removes and then adds the given labels, returning the new
+// labels and what actually changed. The edit is nil when nothing changed.
+func applyLabelEdit(current, add, remove []string) ([]string, *model.ImageLabelEdit) {
+	edit := &model.ImageLabelEdit{
+		Added:   []string{},
+		Removed: []string{},
+	}
+	labels := make([]string, 0, len(current)+len(add))
+	for _, l := range current {
+		if hasLabel(remove, l) {
+			if !hasLabel(edit.Removed, l) {
+				edit.Removed = append(edit.Removed, l)
+			}
+			continue
+		}
+		labels = append(labels, l)
+	}
+	for _, l := range add {
+		if !hasLabel(labels, l) {
+			labels = append(labels, l)
+			edit.Added = append(edit.Added, l)
+		}
+	}
+
+	if len(edit.Added) == 0 && len(edit.Removed) == 0 {
+		return current, nil
+	}
+	return labels, edit
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) imageLabelsHistory(image *model.Image) (*httpImageLabelsHistory, error) {
+	edits, err := model.AllLabelEditsForImage(s.db, image.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &httpImageLabelsHistory{
+		Original:  originalLabelsHTTPStruct(image),
+		Effective: effectiveLabelsHTTPStruct(image),
+		Edits:     make([]*httpImageLabelEdit, len(edits)),
+	}
+	for i, e := range edits {
+		res.Edits[i] = imageLabelEditHTTPStruct(e)
+	}
+	return res, nil
+}
+
+// findUserProjectImage loads the user and the image in the request path,
+// making sure the user has at least the given role on the project of the
+// image.
+func (s *Server) findUserProjectImage(c *fiber.Ctx, role string) (*model.User, *model.Image, error) {
+	imageID, err := uuid.FromString(c.Params("image_id"))
+	if err != nil {
+		return nil, nil, newValidationError("valid image_id is required")
+	}
+
+	user, project, err := s.authorizeProject(c, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	image, err := model.FindImageByID(s.db, imageID)
+	if err != nil {
+		return nil, nil, notFound(err, "image")
+	}
+
+	if image.ProjectID != project.ID {
+		return nil, nil, newNotFoundError("image")
+	}
+	return user, image, nil
+}
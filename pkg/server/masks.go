@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/url"
+
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/mask"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+type httpMaskLegendEntry struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+type getImageMasksResponse struct {
+	Width  int                   `json:"width"`
+	Height int                   `json:"height"`
+	Legend []httpMaskLegendEntry `json:"legend"`
+}
+
+// getImageMasks describes the masks of an image, the legend tells which color
+// each label is drawn with in the combined overlay.
+func (s *Server) getImageMasks(c *fiber.Ctx) error {
+	masks, labels, err := s.findImageMasks(c)
+	if err != nil {
+		return err
+	}
+
+	_, legend, err := mask.Colorize(masks, labels)
+	if err != nil {
+		return err
+	}
+
+	res := getImageMasksResponse{
+		Legend: make([]httpMaskLegendEntry, len(legend)),
+	}
+	if len(masks) > 0 {
+		res.Width, res.Height = masks[0].Width, masks[0].Height
+	}
+	for i, e := range legend {
+		res.Legend[i] = httpMaskLegendEntry{
+			Label: e.Label,
+			Color: mask.HexColor(e.Color),
+		}
+	}
+	return c.JSON(res)
+}
+
+// getImageMasksOverlay renders all the masks of an image as a single
+// transparent PNG, each label in its own color.
+func (s *Server) getImageMasksOverlay(c *fiber.Ctx) error {
+	masks, labels, err := s.findImageMasks(c)
+	if err != nil {
+		return err
+	}
+
+	if len(masks) == 0 {
+		return apierror.New(http.StatusNotFound, "masks_not_found", "image has no masks")
+	}
+
+	img, _, err := mask.Colorize(masks, labels)
+	if err != nil {
+		return err
+	}
+	return sendPNG(c, img)
+}
+
+// getImageLabelMask renders the mask of a single label as a transparent PNG.
+// When the label was found more than once on the image, all its masks are
+// combined.
+func (s *Server) getImageLabelMask(c *fiber.Ctx) error {
+	label, err := url.PathUnescape(c.Params("label"))
+	if err != nil || label == "" {
+		return newValidationError("valid label is required")
+	}
+
+	masks, labels, err := s.findImageMasks(c)
+	if err != nil {
+		return err
+	}
+
+	var combined *mask.Mask
+	for i, l := range labels {
+		if l != label {
+			continue
+		}
+		if combined == nil {
+			combined = mask.New(masks[i].Width, masks[i].Height)
+		}
+		for j, set := range masks[i].Pix {
+			combined.Pix[j] = combined.Pix[j] || set
+		}
+	}
+
+	if combined == nil {
+		return apierror.New(http.StatusNotFound, "mask_not_found", "label has no mask")
+	}
+	return sendPNG(c, mask.Overlay(combined, mask.LabelColor(label)))
+}
+
+// findImageMasks loads and decodes the masks of the image in the request path,
+// making sure the user in the path can view its project.
+func (s *Server) findImageMasks(c *fiber.Ctx) ([]*mask.Mask, []string, error) {
+	_, image, err := s.findUserProjectImage(c, model.RoleViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	labels, data, err := model.FindImageMasks(s.db, image.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data) == 0 {
+		return []*mask.Mask{}, []string{}, nil
+	}
+
+	masks, err := mask.Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(masks) != len(labels) {
+		return nil, nil, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "image masks do not match its labels")
+	}
+	return masks, labels, nil
+}
+
+func sendPNG(c *fiber.Ctx, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	c.Set("Content-Type", "image/png")
+	c.SendBytes(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+type httpProjectMember struct {
+	UserID    string    `json:"userId"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invitedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func projectMemberHTTPStruct(m *model.ProjectMember) *httpProjectMember {
+	res := &httpProjectMember{
+		UserID:    m.UserID.String(),
+		Username:  m.Username,
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+	if m.InvitedBy != nil {
+		res.InvitedBy = m.InvitedBy.String()
+	}
+	return res
+}
+
+// authorizeUser makes sure the user in the request path is the one the
+// token was issued to, so users can only act as themselves. Invalid user
+// IDs are left for the handlers to report.
+func (s *Server) authorizeUser() fiber.Handler {
+	return func(c *fiber.Ctx) {
+		pathUserID, err := uuid.FromString(c.Params("user_id"))
+		if !s.config.Auth.Enabled || err != nil {
+			c.Next()
+			return
+		}
+
+		var tokenUserID uuid.UUID
+		if token, ok := c.Locals("token").(*jwt.Token); ok {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				id, _ := claims["userId"].(string)
+				tokenUserID = uuid.FromStringOrNil(id)
+			}
+		}
+
+		if tokenUserID != pathUserID {
+			c.Next(apierror.New(
+				http.StatusForbidden,
+				apierror.CodeForbidden,
+				"token was not issued to the user in the path",
+			))
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeProject loads the user and the project in the request path,
+// making sure the user is a member of the project with at least the given
+// role. Projects the user is not a member of are reported as missing.
+func (s *Server) authorizeProject(c *fiber.Ctx, role string) (*model.User, *model.Project, error) {
+	user, project, _, err := s.projectMembership(c, role)
+	return user, project, err
+}
+
+// projectMembership is authorizeProject, also returning the membership of
+// the user.
+func (s *Server) projectMembership(c *fiber.Ctx, role string) (
+	*model.User,
+	*model.Project,
+	*model.ProjectMember,
+	error,
+) {
+	userID, err := getUserID(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	projectID, err := uuid.FromString(c.Params("project_id"))
+	if err != nil {
+		return nil, nil, nil, newValidationError("valid project_id is required")
+	}
+
+	user, err := model.FindUserByID(s.db, userID)
+	if err != nil {
+		return nil, nil, nil, notFound(err, "user")
+	}
+
+	project, err := model.FindProjectByID(s.db, projectID)
+	if err != nil {
+		return nil, nil, nil, notFound(err, "project")
+	}
+
+	member, err := model.FindProjectMember(s.db, project.ID, user.ID)
+	if err != nil {
+		return nil, nil, nil, notFound(err, "project")
+	}
+
+	if !model.RoleAllows(member.Role, role) {
+		return nil, nil, nil, errRoleRequired(role)
+	}
+	return user, project, member, nil
+}
+
+func errRoleRequired(role string) error {
+	return apierror.New(
+		http.StatusForbidden,
+		apierror.CodeForbidden,
+		"the "+role+" role on the project is required",
+	)
+}
+
+type getProjectMembersResponse struct {
+	Members []*httpProjectMember `json:"members"`
+}
+
+func (s *Server) getProjectMembers(c *fiber.Ctx) error {
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	members, err := model.AllMembersForProject(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	res := getProjectMembersResponse{
+		Members: make([]*httpProjectMember, len(members)),
+	}
+	for i, m := range members {
+		res.Members[i] = projectMemberHTTPStruct(m)
+	}
+	return c.JSON(res)
+}
+
+type addProjectMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type addProjectMemberResponse struct {
+	Member *httpProjectMember `json:"member"`
+}
+
+var memberRoles = []string{model.RoleOwner, model.RoleEditor, model.RoleViewer}
+
+// addProjectMember gives an existing user a role on the project, only owners
+// can do so.
+func (s *Server) addProjectMember(c *fiber.Ctx) error {
+	var req addProjectMemberRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	err := validate.New().
+		Field("username", req.Username, validate.Required()).
+		Field("role", req.Role, validate.Required(), validate.OneOf(memberRoles...)).
+		Err()
+	if err != nil {
+		return err
+	}
+
+	user, project, err := s.authorizeProject(c, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	invitee, err := model.FindUserByUsername(s.db, req.Username)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	member := &model.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    invitee.ID,
+		Role:      req.Role,
+		InvitedBy: &user.ID,
+		Username:  invitee.Username,
+	}
+	if err := model.AddProjectMember(s.db, member); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(addProjectMemberResponse{
+		Member: projectMemberHTTPStruct(member),
+	})
+}
+
+type updateProjectMemberRequest struct {
+	Role string `json:"role"`
+}
+
+type updateProjectMemberResponse struct {
+	Member *httpProjectMember `json:"member"`
+}
+
+// updateProjectMember changes the role of a member, only owners can do so.
+// The last owner of a project can not be demoted.
+func (s *Server) updateProjectMember(c *fiber.Ctx) error {
+	var req updateProjectMemberRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	err := validate.New().
+		Field("role", req.Role, validate.Required(), validate.OneOf(memberRoles...)).
+		Err()
+	if err != nil {
+		return err
+	}
+
+	_, project, err := s.authorizeProject(c, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	member, err := s.findProjectMember(c, project)
+	if err != nil {
+		return err
+	}
+
+	err = database.Transact(c.Context(), s.db, func(ctx context.Context, tx *gorm.DB) error {
+		return model.SetProjectMemberRole(tx, member, req.Role)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(updateProjectMemberResponse{
+		Member: projectMemberHTTPStruct(member),
+	})
+}
+
+// removeProjectMember takes a member out of the project. Owners can remove
+// anyone, other members can only leave. The last owner can not leave.
+func (s *Server) removeProjectMember(c *fiber.Ctx) error {
+	user, project, membership, err := s.projectMembership(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	member, err := s.findProjectMember(c, project)
+	if err != nil {
+		return err
+	}
+
+	if member.UserID != user.ID && !model.RoleAllows(membership.Role, model.RoleOwner) {
+		return errRoleRequired(model.RoleOwner)
+	}
+
+	err = database.Transact(c.Context(), s.db, func(ctx context.Context, tx *gorm.DB) error {
+		return model.RemoveProjectMember(tx, member)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Status(200).Send()
+	return nil
+}
+
+// findProjectMember loads the member in the request path.
+func (s *Server) findProjectMember(c *fiber.Ctx, project *model.Project) (*model.ProjectMember, error) {
+	memberID, err := uuid.FromString(c.Params("member_id"))
+	if err != nil {
+		return nil, newValidationError("valid member_id is required")
+	}
+
+	member, err := model.FindProjectMember(s.db, project.ID, memberID)
+	if err != nil {
+		return nil, notFound(err, "member")
+	}
+
+	user, err := model.FindUserByID(s.db, member.UserID)
+	if err != nil {
+		return nil, err
+	}
+	member.Username = user.Username
+	return member, nil
+}
@@ -4,67 +4,48 @@ import (
 	"fmt"
 	"net/http"
 	"runtime/debug"
-	"strings"
 
 	"github.com/gofiber/fiber"
 	jwtware "github.com/gofiber/jwt"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
-)
 
-func errorHandler(ctx *fiber.Ctx, err error) {
-	type errResponse struct {
-		Error string `json:"error"`
-		Code  int    `json:"code"`
-	}
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
 
-	logError := func(err error) {
-		if err == nil {
-			return
-		}
-		zap.L().Error("failed to send error response", zap.Error(err))
-	}
+type httpError struct {
+	Error     string                `json:"error"`
+	Code      string                `json:"code"`
+	Status    int                   `json:"status"`
+	Fields    []apierror.FieldError `json:"fields,omitempty"`
+	RequestID string                `json:"requestId,omitempty"`
+}
 
-	// weirdly this error is not type, so it has to be string matched
-	if has := strings.HasPrefix(err.Error(), "bodyparser: cannot parse content-type:"); has {
-		logError(ctx.Status(http.StatusBadRequest).JSON(errResponse{
-			Error: "Content-Type: application/json header is required",
-			Code:  http.StatusBadRequest,
-		}))
-		return
+// errorHandler responds with the API error matching err. Errors that are
+// not meant for clients are logged and masked as internal errors.
+func errorHandler(ctx *fiber.Ctx, err error) {
+	switch err {
+	case model.ErrVersionConflict:
+		err = errPreconditionFailed
+	case model.ErrLastOwner:
+		err = apierror.New(http.StatusConflict, apierror.CodeLastOwner, err.Error())
 	}
 
-	// this is one of those, we have no choice
-	if has := strings.HasPrefix(err.Error(), "pq: duplicate key value violates unique constraint"); has {
-		logError(ctx.Status(http.StatusConflict).JSON(errResponse{
-			Error: "record already exists",
-			Code:  http.StatusConflict,
-		}))
-		return
+	e := apierror.From(err)
+	if e.Status >= http.StatusInternalServerError {
+		zap.L().Info("masking internal error: ", zap.Error(err))
 	}
 
-	if err == gorm.ErrRecordNotFound {
-		logError(ctx.Status(http.StatusNotFound).JSON(errResponse{
-			Error: "record does not exist",
-			Code:  http.StatusNotFound,
-		}))
-		return
+	res := httpError{
+		Error:     e.Message,
+		Code:      e.Code,
+		Status:    e.Status,
+		Fields:    e.Fields,
+		RequestID: string(ctx.Fasthttp.Response.Header.Peek(fiber.HeaderXRequestID)),
 	}
-
-	e, ok := err.(PublicError)
-	if !ok {
-		zap.L().Info("masking internal error: ", zap.Error(err))
-		logError(ctx.Status(500).JSON(errResponse{
-			Error: "internal error",
-			Code:  500,
-		}))
-		return
+	if err := ctx.Status(e.Status).JSON(res); err != nil {
+		zap.L().Error("failed to send error response", zap.Error(err))
 	}
-
-	logError(ctx.Status(e.Code()).JSON(errResponse{
-		Error: e.PublicError(),
-		Code:  e.Code(),
-	}))
 }
 
 // Custom recover middleware to get stacktrace printed on error
@@ -109,10 +90,10 @@ func (s *Server) protected() fiber.Handler {
 func jwtError(c *fiber.Ctx, err error) {
 	fmt.Printf("error jwt: %+v\n\n", err)
 	if err.Error() == "Missing or malformed JWT" {
-		c.Next(newValidationError("missing or malformed JWT"))
+		c.Next(apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "missing or malformed JWT"))
 		return
 	}
 
-	c.Next(newPublicError("invalid or expired JWT", 401))
+	c.Next(apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "invalid or expired JWT"))
 	return
 }
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/conf"
+)
+
+// TestProtectedRoutesRequireJWT checks requests without a valid token are
+// unauthorized, whatever is wrong with the token.
+func TestProtectedRoutesRequireJWT(t *testing.T) {
+	config := &conf.Config{}
+	config.Auth.Enabled = true
+	config.Auth.TokenSecret = "secret"
+	srv := NewServer(config, nil)
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"missing", ""},
+		{"malformed", "Token abc"},
+		{"invalid", "Bearer not.a.jwt"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, apiPrefix+"/users/6f1c2b0e-3a4d-4e5f-8a9b-0c1d2e3f4a5b/projects", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		res, err := srv.app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body httpError
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("%s token: %v", tt.name, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized || body.Code != apierror.CodeUnauthorized {
+			t.Errorf("%s token: got %d %s, want %d %s",
+				tt.name, res.StatusCode, body.Code, http.StatusUnauthorized, apierror.CodeUnauthorized)
+		}
+	}
+}
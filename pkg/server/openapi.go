@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/openapi"
+)
+
+const bearerAuth = "bearerAuth"
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+func (s *Server) getOpenAPI(c *fiber.Ctx) error {
+	openAPIOnce.Do(func() {
+		openAPIJSON, openAPIErr = json.Marshal(s.openAPIDocument())
+	})
+	if openAPIErr != nil {
+		return openAPIErr
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	c.SendBytes(openAPIJSON)
+	return nil
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>pyvinci API</title>
+  <meta charset="utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="` + apiPrefix + `/openapi.json"></redoc>
+  <script src="https://cdn.jsdelivr.net/npm/redoc@2/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+func (s *Server) getDocs(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	c.SendString(docsPage)
+	return nil
+}
+
+// openAPIDocument describes the routes of the server.
+func (s *Server) openAPIDocument() *openapi.Document {
+	gen := openapi.NewGenerator()
+	errorSchema := gen.SchemaFor(httpError{})
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   "pyvinci API",
+			Version: "1",
+		},
+		Servers: []openapi.Server{{URL: apiPrefix}},
+		Paths:   map[string]*openapi.PathItem{},
+	}
+
+	for _, r := range s.routes() {
+		path := openAPIPath(r.path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(r.method)] = r.operation(gen, errorSchema)
+	}
+
+	doc.Components = openapi.Components{
+		Schemas: gen.Schemas(),
+		SecuritySchemes: map[string]*openapi.SecurityScheme{
+			bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+	return doc
+}
+
+func (r route) operation(gen *openapi.Generator, errorSchema *openapi.Schema) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: operationID(r.handler),
+		Summary:     r.summary,
+		Tags:        []string{r.tag},
+		Responses: map[string]*openapi.Response{
+			"default": {
+				Description: "error",
+				Content:     map[string]openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: errorSchema}},
+			},
+		},
+		Security: []openapi.SecurityRequirement{},
+	}
+	if !r.public {
+		op.Security = []openapi.SecurityRequirement{{bearerAuth: {}}}
+	}
+
+	for _, name := range pathParams(r.path) {
+		p := &openapi.Parameter{Name: name, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+		if strings.HasSuffix(name, "_id") {
+			p.Schema.Format = "uuid"
+		}
+		op.Parameters = append(op.Parameters, p)
+	}
+	for _, p := range r.params {
+		op.Parameters = append(op.Parameters, p.parameter())
+	}
+	if r.idempotent {
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:        headerIdempotencyKey,
+			In:          "header",
+			Description: "retries with the same key replay the first response",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+	}
+
+	if r.conditional {
+		header := fiber.HeaderIfMatch
+		if r.method == fiber.MethodGet {
+			header = fiber.HeaderIfNoneMatch
+		}
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:   header,
+			In:     "header",
+			Schema: &openapi.Schema{Type: "string"},
+		})
+		if r.method == fiber.MethodGet {
+			op.Responses[strconv.Itoa(http.StatusNotModified)] = &openapi.Response{Description: "not modified"}
+		} else {
+			op.Responses[strconv.Itoa(http.StatusPreconditionFailed)] = &openapi.Response{
+				Description: "the ETag does not match",
+				Content:     map[string]openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: errorSchema}},
+			}
+		}
+	}
+
+	if r.noContent != "" {
+		op.Responses[strconv.Itoa(http.StatusNoContent)] = &openapi.Response{Description: r.noContent}
+	}
+
+	if r.request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: gen.SchemaFor(r.request)}},
+		}
+	}
+	if r.upload != "" {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				fiber.MIMEMultipartForm: {Schema: &openapi.Schema{
+					Type: "object",
+					Properties: map[string]*openapi.Schema{
+						r.upload: {Type: "array", Items: &openapi.Schema{Type: "string", Format: "binary"}},
+					},
+					Required: []string{r.upload},
+				}},
+			},
+		}
+	}
+
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res := &openapi.Response{Description: http.StatusText(status)}
+	switch {
+	case r.response != nil:
+		res.Content = map[string]openapi.MediaType{fiber.MIMEApplicationJSON: {Schema: gen.SchemaFor(r.response)}}
+	case r.contentType != "":
+		res.Content = map[string]openapi.MediaType{}
+		for _, ct := range strings.Split(r.contentType, ", ") {
+			res.Content[ct] = openapi.MediaType{}
+		}
+	}
+	if r.conditional && r.response != nil {
+		res.Headers = map[string]*openapi.Header{
+			fiber.HeaderETag: {Schema: &openapi.Schema{Type: "string"}},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = res
+	return op
+}
+
+// operationID is the name of the handler method, createProject for
+// s.createProject.
+func operationID(h Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+func (p param) parameter() *openapi.Parameter {
+	schema := &openapi.Schema{Type: p.kind, Enum: p.enum}
+	if p.kind == "date-time" {
+		schema = &openapi.Schema{Type: "string", Format: "date-time"}
+	}
+	if p.multi {
+		schema = &openapi.Schema{Type: "array", Items: schema}
+	}
+	return &openapi.Parameter{
+		Name:        p.name,
+		In:          p.in,
+		Description: p.description,
+		Required:    p.required,
+		Schema:      schema,
+	}
+}
+
+// openAPIPath turns the fiber parameters of path into OpenAPI templates,
+// /projects/:project_id becomes /projects/{project_id}.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			name, ext := splitParam(seg[1:])
+			segments[i] = "{" + name + "}" + ext
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, ":") {
+			name, _ := splitParam(seg[1:])
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// splitParam separates a parameter from a literal suffix such as the
+// extension in :label.png.
+func splitParam(seg string) (string, string) {
+	if i := strings.IndexByte(seg, '.'); i >= 0 {
+		return seg[:i], seg[i:]
+	}
+	return seg, ""
+}
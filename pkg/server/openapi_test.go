@@ -0,0 +1,236 @@
+package server
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/caquillo07/pyvinci-server/pkg/conf"
+)
+
+// TestOpenAPIMatchesRoutes fails when a route is registered without being
+// documented, or documented without being registered.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	srv := NewServer(&conf.Config{}, nil)
+
+	registered := map[string]bool{}
+	for _, stack := range srv.app.Stack() {
+		for _, r := range stack {
+			// middleware of the app and the API group are not routes
+			if !strings.HasPrefix(r.Path, apiPrefix+"/") {
+				continue
+			}
+			key := r.Method + " " + openAPIPath(strings.TrimPrefix(r.Path, apiPrefix))
+			if registered[key] {
+				t.Errorf("%s is registered more than once", key)
+			}
+			registered[key] = true
+		}
+	}
+
+	documented := map[string]bool{}
+	for path, item := range srv.openAPIDocument().Paths {
+		for method := range *item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, key := range sortedRoutes(registered) {
+		if !documented[key] {
+			t.Errorf("%s is registered but not in the OpenAPI document", key)
+		}
+	}
+	for _, key := range sortedRoutes(documented) {
+		if !registered[key] {
+			t.Errorf("%s is in the OpenAPI document but not registered", key)
+		}
+	}
+}
+
+// TestPublicRoutesSkipAuth checks the public routes are registered before
+// the protected middleware, and every other route after it.
+func TestPublicRoutesSkipAuth(t *testing.T) {
+	srv := NewServer(&conf.Config{}, nil)
+
+	public := map[string]bool{}
+	for _, r := range srv.routes() {
+		public[r.method+" "+apiPrefix+r.path] = r.public
+	}
+
+	for _, stack := range srv.app.Stack() {
+		protected := false
+		for _, r := range stack {
+			// the protected middleware is the only handler of the API group
+			// itself
+			if r.Path == apiPrefix {
+				protected = true
+				continue
+			}
+			key := r.Method + " " + r.Path
+			isPublic, ok := public[key]
+			if !ok {
+				continue
+			}
+			if isPublic && protected {
+				t.Errorf("public route %s is registered after the protected middleware", key)
+			}
+			if !isPublic && !protected {
+				t.Errorf("route %s is registered before the protected middleware", key)
+			}
+		}
+	}
+}
+
+// TestRouteStatus checks the success status documented for each route is
+// the one its handler responds with. Handlers need a database to run, so
+// the status is read from the c.Status calls in their source instead.
+func TestRouteStatus(t *testing.T) {
+	fset := token.NewFileSet()
+	methods := serverMethods(t, fset)
+
+	srv := NewServer(&conf.Config{}, nil)
+	for _, r := range srv.routes() {
+		name := operationID(r.handler)
+		fn, ok := methods[name]
+		if !ok {
+			t.Errorf("handler %s of %s %s not found", name, r.method, r.path)
+			continue
+		}
+
+		want := r.status
+		if want == 0 {
+			want = http.StatusOK
+		}
+		got := successStatuses(t, fset, fn)
+		if r.noContent != "" {
+			got = withoutStatus(got, http.StatusNoContent)
+		}
+		if len(got) == 0 {
+			// no status set, fiber responds with 200
+			got = []int{http.StatusOK}
+		}
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("%s %s is documented as %d but %s responds with %v", r.method, r.path, want, name, got)
+		}
+	}
+}
+
+// serverMethods parses the package source, returning the methods of the
+// Server by name.
+func serverMethods(t *testing.T, fset *token.FileSet) map[string]*ast.FuncDecl {
+	t.Helper()
+
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := map[string]*ast.FuncDecl{}
+	for _, f := range pkgs["server"].Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if ok && fn.Recv != nil && fn.Body != nil && isServerReceiver(fn.Recv) {
+				methods[fn.Name.Name] = fn
+			}
+		}
+	}
+	return methods
+}
+
+// successStatuses returns the 2xx statuses passed to Status in fn.
+func successStatuses(t *testing.T, fset *token.FileSet, fn *ast.FuncDecl) []int {
+	t.Helper()
+
+	seen := map[int]bool{}
+	var codes []int
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Status" {
+			return true
+		}
+		code, ok := statusCode(call.Args[0])
+		if !ok {
+			t.Errorf("%s: status of %s can not be read", fset.Position(call.Pos()), fn.Name.Name)
+			return true
+		}
+		if code >= 200 && code < 300 && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+		return true
+	})
+	sort.Ints(codes)
+	return codes
+}
+
+func withoutStatus(codes []int, code int) []int {
+	var res []int
+	for _, c := range codes {
+		if c != code {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func isServerReceiver(recv *ast.FieldList) bool {
+	if len(recv.List) != 1 {
+		return false
+	}
+	star, ok := recv.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := star.X.(*ast.Ident)
+	return ok && ident.Name == "Server"
+}
+
+// httpStatuses are the net/http constants handlers may respond with.
+var httpStatuses = map[string]int{
+	"StatusOK":             http.StatusOK,
+	"StatusCreated":        http.StatusCreated,
+	"StatusAccepted":       http.StatusAccepted,
+	"StatusNoContent":      http.StatusNoContent,
+	"StatusNotModified":    http.StatusNotModified,
+	"StatusPartialContent": http.StatusPartialContent,
+}
+
+func statusCode(expr ast.Expr) (int, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.INT {
+			return 0, false
+		}
+		code, err := strconv.Atoi(e.Value)
+		return code, err == nil
+	case *ast.SelectorExpr:
+		pkg, ok := e.X.(*ast.Ident)
+		if !ok || pkg.Name != "http" {
+			return 0, false
+		}
+		code, ok := httpStatuses[e.Sel.Name]
+		return code, ok
+	}
+	return 0, false
+}
+
+func sortedRoutes(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
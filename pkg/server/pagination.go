@@ -0,0 +1,77 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// parsePage reads the limit, cursor and sort query parameters shared by all
+// listings, adding the invalid ones to v. Listings are sorted by creation
+// time, newest first unless sort=created_at is given.
+func parsePage(c *fiber.Ctx, v *validate.Validator) model.Page {
+	var page model.Page
+
+	limit, err := queryInt(c, "limit", defaultPageLimit)
+	if err != nil {
+		v.AddError("limit", "must be a number")
+	} else {
+		v.Field("limit", limit, validate.Range(1, maxPageLimit))
+	}
+	page.Limit = limit
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		page.After, err = model.ParseCursor(cursor)
+		if err != nil {
+			v.AddError("cursor", "is not valid")
+		}
+	}
+
+	sort := c.Query("sort", "-created_at")
+	v.Field("sort", sort, validate.OneOf("-created_at", "created_at"))
+	page.Ascending = sort == "created_at"
+	return page
+}
+
+// nextCursorString returns the encoded cursor, or an empty string for the
+// last page.
+func nextCursorString(cursor *model.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+	return cursor.String()
+}
+
+// queryInt parses an integer query parameter, returning def when missing.
+func queryInt(c *fiber.Ctx, key string, def int) (int, error) {
+	v := c.Query(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// queryTime parses an RFC 3339 time query parameter, returning nil when
+// missing or invalid, in which case the error is added to v.
+func queryTime(c *fiber.Ctx, v *validate.Validator, key string) *time.Time {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 time")
+		return nil
+	}
+	return &t
+}
@@ -1,8 +1,18 @@
 package server
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"image"
+	_ "image/gif"  // registers the gif format with image.DecodeConfig
+	_ "image/jpeg" // registers the jpeg format with image.DecodeConfig
+	"io"
+	"mime/multipart"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,27 +25,47 @@ import (
 	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
 	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
 )
 
 type httpProject struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"userId"`
-	Name      string    `json:"name"`
-	Keywords  []string  `json:"keywords"`
-	Labels    []string  `json:"labels"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID             string    `json:"id"`
+	UserID         string    `json:"userId"`
+	Name           string    `json:"name"`
+	Keywords       []string  `json:"keywords"`
+	Labels         []string  `json:"labels"`
+	Status         string    `json:"status"`
+	ResultImageURL string    `json:"resultImageUrl,omitempty"`
+	Job            *httpJob  `json:"job,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
 }
 
 type httpImage struct {
-	ID        string    `json:"id"`
-	URL       string    `json:"url"`
-	ProjectID string    `json:"projectId"`
-	Labels    []string  `json:"labels,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        string           `json:"id"`
+	URL       string           `json:"url"`
+	Checksum  string           `json:"checksum,omitempty"`
+	ProjectID string           `json:"projectId"`
+	Labels    *httpImageLabels `json:"labels"`
+
+	// OriginalLabels are the labels found by the model, only set when the
+	// user corrected them
+	OriginalLabels *httpImageLabels `json:"originalLabels,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+}
+
+// httpImageLabels keeps the labels of each category apart. Things are
+// countable objects, stuff are amorphous regions such as sky or grass, and
+// masks are the labels that come with a segmentation mask.
+type httpImageLabels struct {
+	Things []string           `json:"things"`
+	Stuff  []string           `json:"stuff"`
+	Masks  []string           `json:"masks"`
+	Scores map[string]float64 `json:"scores,omitempty"`
 }
 
 func projectHTTPStruct(p *model.Project) *httpProject {
@@ -50,51 +80,81 @@ func projectHTTPStruct(p *model.Project) *httpProject {
 }
 
 func imageHTTPStruct(img *model.Image) *httpImage {
-	uniqueLabels := map[string]struct{}{}
-	assignLabels := func(s []string) {
-		for _, l := range s {
-			uniqueLabels[l] = struct{}{}
-		}
-	}
-	assignLabels(img.MasksLabels)
-	assignLabels(img.LabelsStuff)
-	labels := make([]string, 0)
-	for l, _ := range uniqueLabels {
-		labels = append(labels, l)
-	}
-
-	return &httpImage{
+	res := &httpImage{
 		ID:        img.ID.String(),
 		URL:       img.URL,
-		Labels:    labels,
+		Labels:    effectiveLabelsHTTPStruct(img),
 		ProjectID: img.ProjectID.String(),
 		CreatedAt: img.CreatedAt,
 		UpdatedAt: img.UpdatedAt,
 	}
+	if img.Checksum != nil {
+		res.Checksum = *img.Checksum
+	}
+	if img.IsLabelsEdited() {
+		res.OriginalLabels = originalLabelsHTTPStruct(img)
+	}
+	return res
 }
 
-func (s *Server) createProject(c *fiber.Ctx) error {
-	type CreateRequest struct {
-		Name     string   `json:"name"`
-		Keywords []string `json:"keywords"`
+// originalLabelsHTTPStruct returns the labels as found by the model.
+func originalLabelsHTTPStruct(img *model.Image) *httpImageLabels {
+	return &httpImageLabels{
+		Things: sortedLabels(img.LabelsThings),
+		Stuff:  sortedLabels(img.LabelsStuff),
+		Masks:  sortedLabels(img.MasksLabels),
+		Scores: img.LabelScores,
+	}
+}
+
+// effectiveLabelsHTTPStruct returns the labels as corrected by the user.
+func effectiveLabelsHTTPStruct(img *model.Image) *httpImageLabels {
+	return &httpImageLabels{
+		Things: sortedLabels(img.EffectiveThings()),
+		Stuff:  sortedLabels(img.EffectiveStuff()),
+		Masks:  sortedLabels(img.MasksLabels),
+		Scores: img.LabelScores,
 	}
-	type CreateResponse struct {
-		Project *httpProject `json:"project"`
+}
+
+// sortedLabels returns the unique labels in alphabetical order.
+func sortedLabels(labels []string) []string {
+	unique := map[string]struct{}{}
+	for _, l := range labels {
+		unique[l] = struct{}{}
+	}
+
+	res := make([]string, 0, len(unique))
+	for l := range unique {
+		res = append(res, l)
 	}
+	sort.Strings(res)
+	return res
+}
+
+type createProjectRequest struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+}
+
+type createProjectResponse struct {
+	Project *httpProject `json:"project"`
+}
 
+func (s *Server) createProject(c *fiber.Ctx) error {
 	userID, err := getUserID(c)
 	if err != nil {
 		return newValidationError("valid user_id is required")
 	}
 
-	var req CreateRequest
-	if err := c.BodyParser(&req); err != nil {
+	var req createProjectRequest
+	if err := parseBody(c, &req); err != nil {
 		return err
 	}
 
 	user, err := model.FindUserByID(s.db, userID)
 	if err != nil {
-		return err
+		return notFound(err, "user")
 	}
 
 	newProject := &model.Project{
@@ -102,39 +162,69 @@ func (s *Server) createProject(c *fiber.Ctx) error {
 		UserID:   user.ID,
 		Name:     req.Name,
 	}
-	if err := model.CreateProject(s.db, newProject); err != nil {
+	if err := validateProject(newProject); err != nil {
+		return err
+	}
+
+	err = database.Transact(c.Context(), s.db, func(ctx context.Context, tx *gorm.DB) error {
+		return model.CreateProject(tx, newProject)
+	})
+	if err != nil {
 		return err
 	}
 
-	return c.Status(http.StatusCreated).JSON(CreateResponse{
+	return c.Status(http.StatusCreated).JSON(createProjectResponse{
 		Project: projectHTTPStruct(newProject),
 	})
 }
 
-// returns a list of all projects for the given user.
-// this endpoint does not support pagination as its not required for
-// this application
+type getProjectsResponse struct {
+	Projects   []*httpProject `json:"projects"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// returns a page of the projects of the given user, newest first by
+// default. Projects can be filtered by name prefix, keyword, creation time
+// and the status of their latest job.
 func (s *Server) getProjects(c *fiber.Ctx) error {
-	type GetResponse struct {
-		Projects []*httpProject `json:"projects"`
-	}
 	userID, err := getUserID(c)
 	if err != nil {
 		return newValidationError("valid user_id is required")
 	}
 
+	v := validate.New()
+	page := parsePage(c, v)
+	filter := model.ProjectFilter{
+		NamePrefix:    c.Query("name"),
+		Keyword:       c.Query("keyword"),
+		JobStatus:     c.Query("status"),
+		CreatedAfter:  queryTime(c, v, "created_after"),
+		CreatedBefore: queryTime(c, v, "created_before"),
+	}
+	v.Field("status", filter.JobStatus, validate.OneOf(
+		model.JobStatusQueued,
+		model.JobStatusPendingLabels,
+		model.JobStatusProcessing,
+		model.JobStatusFinished,
+		model.JobStatusFailed,
+	))
+	if err := v.Err(); err != nil {
+		return err
+	}
+
 	user, err := model.FindUserByID(s.db, userID)
 	if err != nil {
-		return err
+		return notFound(err, "user")
 	}
 
-	projects, err := model.AllProjectsForUser(s.db, user.ID)
+	projects, next, err := model.ListProjectsForUser(s.db, user.ID, filter, page)
 	if err != nil {
 		return err
 	}
 
-	res := GetResponse{
-		Projects: make([]*httpProject, len(projects)),
+	res := getProjectsResponse{
+		Projects:   make([]*httpProject, len(projects)),
+		NextCursor: nextCursorString(next),
 	}
 
 	for i, p := range projects {
@@ -144,167 +234,232 @@ func (s *Server) getProjects(c *fiber.Ctx) error {
 	return c.JSON(res)
 }
 
-func (s *Server) getProject(c *fiber.Ctx) error {
-	type GetResponse struct {
-		Project *httpProject `json:"project"`
-	}
-	userID, err := getUserID(c)
-	if err != nil {
-		return newValidationError("valid user_id is required")
-	}
-
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
-	}
+type getProjectResponse struct {
+	Project *httpProject `json:"project"`
+}
 
-	user, err := model.FindUserByID(s.db, userID)
+func (s *Server) getProject(c *fiber.Ctx) error {
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
 	if err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
+	projectRes, err := s.projectDetails(project)
 	if err != nil {
 		return err
 	}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
-	}
+	return conditionalJSON(c, project.Version, getProjectResponse{
+		Project: projectRes,
+	})
+}
 
+// projectDetails returns the project along with the status of its latest
+// job and the labels found on its images.
+func (s *Server) projectDetails(project *model.Project) (*httpProject, error) {
 	projectRes := projectHTTPStruct(project)
 
 	// see if the project has a pending job to fill out the information
-	job, err := model.FindJobForProject(s.db, projectID)
+	job, err := model.FindJobForProject(s.db, project.ID)
 	if err != nil && !gorm.IsRecordNotFoundError(err) {
-		return err
+		return nil, err
 	}
 
 	if job != nil {
 		projectRes.Status = job.Status
+		projectRes.Job = jobHTTPStruct(job)
+		if job.Status == model.JobStatusQueued {
+			projectRes.Job.QueuePosition, err = model.QueuePosition(s.db, job)
+			if err != nil {
+				return nil, err
+			}
+		}
+		projectRes.ResultImageURL = projectRes.Job.ResultImageURL
 	}
 
-	return c.JSON(GetResponse{
-		Project: projectRes,
-	})
+	labels, err := model.AllLabelsForProject(s.db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	projectRes.Labels = labels
+	return projectRes, nil
 }
 
 func (s *Server) deleteProject(c *fiber.Ctx) error {
-	userID, err := getUserID(c)
+	_, project, err := s.authorizeProject(c, model.RoleOwner)
 	if err != nil {
-		return newValidationError("valid user_id is required")
+		return err
 	}
 
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
+	if c.Get(fiber.HeaderIfMatch) != "" {
+		if err := checkIfMatch(c, project.Version); err != nil {
+			return err
+		}
+		err = model.DeleteProjectVersion(s.db, project.ID, project.Version)
+	} else {
+		err = model.DeleteProjectByID(s.db, project.ID)
 	}
-
-	user, err := model.FindUserByID(s.db, userID)
 	if err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
-	if err != nil {
-		return err
-	}
+	c.Status(200).Send()
+	return nil
+}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
-	}
+const (
+	maxUploadImages      = 50
+	maxProjectNameLength = 100
+	maxProjectKeywords   = 50
+	maxKeywordLength     = 50
+)
 
-	if err := model.DeleteProjectByID(s.db, project.ID); err != nil {
-		return err
-	}
+type replaceProjectRequest struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+}
 
-	c.Status(200).Send()
-	return nil
+// patchProjectRequest only describes the body of patchProject, which is
+// decoded key by key so that null can be told apart from a missing field.
+type patchProjectRequest struct {
+	Name     *string   `json:"name,omitempty"`
+	Keywords *[]string `json:"keywords,omitempty"`
 }
 
-func (s *Server) updateProject(c *fiber.Ctx) error {
-	type UpdateRequest struct {
-		Keywords []string `json:"keywords"`
+// replaceProject replaces every editable field of the project. Fields left
+// out of the request are reset, so the name is required.
+func (s *Server) replaceProject(c *fiber.Ctx) error {
+	var req replaceProjectRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	type CreateResponse struct {
-		Project *httpProject `json:"project"`
-	}
-	userID, err := getUserID(c)
-	if err != nil {
-		return newValidationError("valid user_id is required")
+	if req.Keywords == nil {
+		req.Keywords = []string{}
 	}
 
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
-	}
+	return s.updateProject(c, func(project *model.Project) error {
+		project.Name = req.Name
+		project.Keywords = req.Keywords
+		return nil
+	})
+}
+
+// patchProject applies a JSON Merge Patch (RFC 7396) to the project. Only
+// the fields present in the request are changed, a null keywords clears
+// them.
+func (s *Server) patchProject(c *fiber.Ctx) error {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(c.Fasthttp.Request.Body(), &patch); err != nil || patch == nil {
+		return newValidationError("request body must be a JSON object")
+	}
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return s.updateProject(c, func(project *model.Project) error {
+		v := validate.New()
+		for _, key := range keys {
+			value := patch[key]
+			isNull := string(value) == "null"
+			switch key {
+			case "name":
+				if isNull || json.Unmarshal(value, &project.Name) != nil {
+					v.AddError(key, "must be a string")
+				}
+			case "keywords":
+				var keywords []string
+				if !isNull && json.Unmarshal(value, &keywords) != nil {
+					v.AddError(key, "must be a list of strings")
+					continue
+				}
+				if keywords == nil {
+					keywords = []string{}
+				}
+				project.Keywords = keywords
+			default:
+				v.AddError(key, "can not be updated")
+			}
+		}
 
-	var req UpdateRequest
-	if err := c.BodyParser(&req); err != nil {
+		return v.Err()
+	})
+}
+
+type updateProjectResponse struct {
+	Project *httpProject `json:"project"`
+}
+
+// updateProject loads the project in the request path, applies the given
+// changes to it, validates and saves it.
+func (s *Server) updateProject(c *fiber.Ctx, apply func(project *model.Project) error) error {
+	_, project, err := s.authorizeProject(c, model.RoleEditor)
+	if err != nil {
 		return err
 	}
 
-	user, err := model.FindUserByID(s.db, userID)
-	if err != nil {
+	if err := checkIfMatch(c, project.Version); err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
-	if err != nil {
+	if err := apply(project); err != nil {
 		return err
 	}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
+	if err := validateProject(project); err != nil {
+		return err
 	}
 
-	project.Keywords = req.Keywords
 	if err := project.Update(s.db); err != nil {
 		return err
 	}
 
-	return c.JSON(CreateResponse{
+	return jsonWithETag(c, project.Version, updateProjectResponse{
 		Project: projectHTTPStruct(project),
 	})
 }
 
-func (s *Server) postProjectImage(c *fiber.Ctx) error {
-	type CreateResponse struct {
-		Images []*httpImage `json:"images"`
+// validateProject trims and checks the editable fields of the project.
+func validateProject(project *model.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	for i, k := range project.Keywords {
+		project.Keywords[i] = strings.TrimSpace(k)
 	}
 
-	userID, err := getUserID(c)
-	if err != nil {
-		return newValidationError("valid user_id is required")
-	}
+	return validate.New().
+		Field("name", project.Name, validate.Required(), validate.Length(1, maxProjectNameLength)).
+		Field("keywords", []string(project.Keywords), validate.Items(0, maxProjectKeywords)).
+		Each("keywords", project.Keywords, validate.Required(), validate.Length(1, maxKeywordLength)).
+		Err()
+}
 
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
-	}
+type postProjectImageResponse struct {
+	Images []*httpImage `json:"images"`
+}
 
-	user, err := model.FindUserByID(s.db, userID)
+func (s *Server) postProjectImage(c *fiber.Ctx) error {
+	_, project, err := s.authorizeProject(c, model.RoleEditor)
 	if err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
+	s3Client, err := s.s3Client()
 	if err != nil {
 		return err
 	}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
-	}
-
-	s3Client, err := s.s3Client()
+	// Parse the multipart form:
+	form, err := c.MultipartForm()
 	if err != nil {
 		return err
 	}
 
-	// Parse the multipart form:
-	form, err := c.MultipartForm()
+	err = validate.New().
+		Field("images", form.File["images"], validate.Required(), validate.Items(1, maxUploadImages)).
+		Err()
 	if err != nil {
 		return err
 	}
@@ -326,11 +481,29 @@ func (s *Server) postProjectImage(c *fiber.Ctx) error {
 			return err
 		}
 
+		var width, height *int
+
+		checksum, err := fileChecksum(mpFile)
+		if err == nil {
+			width, height, err = imageSize(mpFile)
+		}
+		if err != nil {
+			if err := mpFile.Close(); err != nil {
+				zap.L().Error(
+					"error closing fileHeader",
+					zap.Error(err),
+					zap.String("file_name", fileHeader.Filename),
+				)
+			}
+			return err
+		}
+
 		// Save the files to disk:
 		// err := c.SaveFile(file, fmt.Sprintf("./%s", file.Filename))
 		// Check for errors
+		// images are kept under the project's creator whoever uploads them
 		imageKey := s3ImageKey(
-			user.ID,
+			project.UserID,
 			project.ID,
 			uuid.Must(uuid.NewV4()).String()+"_"+fileHeader.Filename,
 		)
@@ -363,6 +536,9 @@ func (s *Server) postProjectImage(c *fiber.Ctx) error {
 		image := &model.Image{
 			URL:       s3ImageURL(s.config.S3.ImageBucket, imageKey),
 			ProjectID: project.ID,
+			Checksum:  &checksum,
+			Width:     width,
+			Height:    height,
 		}
 		if err := model.CreateImage(s.db, image); err != nil {
 			return err
@@ -373,11 +549,38 @@ func (s *Server) postProjectImage(c *fiber.Ctx) error {
 	for i, img := range images {
 		response[i] = imageHTTPStruct(img)
 	}
-	return c.Status(http.StatusCreated).JSON(CreateResponse{
+	return c.Status(http.StatusCreated).JSON(postProjectImageResponse{
 		Images: response,
 	})
 }
 
+// fileChecksum returns the hex encoded sha256 of the file contents, leaving the
+// file ready to be read again from the start.
+func fileChecksum(f multipart.File) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// imageSize returns the dimensions of the image in the file, leaving the file
+// ready to be read again from the start. If the image format is not known the
+// size is nil.
+func imageSize(f multipart.File) (*int, *int, error) {
+	config, _, decodeErr := image.DecodeConfig(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	if decodeErr != nil {
+		return nil, nil, nil
+	}
+	return &config.Width, &config.Height, nil
+}
+
 func (s *Server) s3Client() (*s3.S3, error) {
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String("us-east-1"),
@@ -395,198 +598,195 @@ func (s *Server) s3Client() (*s3.S3, error) {
 	return s3.New(sess), nil
 }
 
-func (s *Server) getProjectImages(c *fiber.Ctx) error {
-	type GetResponse struct {
-		Images []*httpImage `json:"images"`
-	}
+type getProjectImagesResponse struct {
+	Images     []*httpImage `json:"images"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
 
-	userID, err := getUserID(c)
+// returns a page of the images of the project.
+func (s *Server) getProjectImages(c *fiber.Ctx) error {
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
 	if err != nil {
-		return newValidationError("valid user_id is required")
+		return err
 	}
+	return s.sendProjectImages(c, project)
+}
 
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
+// sendProjectImages responds with a page of the images of the project,
+// newest first by default. Images can be filtered by creation time.
+func (s *Server) sendProjectImages(c *fiber.Ctx, project *model.Project) error {
+	v := validate.New()
+	page := parsePage(c, v)
+	filter := model.ImageFilter{
+		CreatedAfter:  queryTime(c, v, "created_after"),
+		CreatedBefore: queryTime(c, v, "created_before"),
 	}
-
-	user, err := model.FindUserByID(s.db, userID)
-	if err != nil {
+	if err := v.Err(); err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
+	images, next, err := model.ListImagesForProject(s.db, project.ID, filter, page)
 	if err != nil {
 		return err
 	}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
-	}
-
-	images, err := model.AllImagesForProject(s.db, projectID)
-	if err != nil {
-		return nil
-	}
-
 	httpImages := make([]*httpImage, len(images))
 	for i, img := range images {
 		httpImages[i] = imageHTTPStruct(img)
 	}
-	return c.JSON(GetResponse{
-		Images: httpImages,
+	return c.JSON(getProjectImagesResponse{
+		Images:     httpImages,
+		NextCursor: nextCursorString(next),
 	})
 }
 
-func (s *Server) getProjectImage(c *fiber.Ctx) error {
-	type GetResponse struct {
-		Image *httpImage `json:"image"`
-	}
-	userID, err := getUserID(c)
-	if err != nil {
-		return newValidationError("valid user_id is required")
-	}
-
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
-	}
-
-	imageID, err := uuid.FromString(c.Params("image_id"))
-	if err != nil {
-		return newValidationError("valid image_id is required")
-	}
-
-	user, err := model.FindUserByID(s.db, userID)
-	if err != nil {
-		return err
-	}
-
-	project, err := model.FindProjectByID(s.db, projectID)
-	if err != nil {
-		return err
-	}
-
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
-	}
+type getProjectImageResponse struct {
+	Image *httpImage `json:"image"`
+}
 
-	image, err := model.FindImageByID(s.db, imageID)
+func (s *Server) getProjectImage(c *fiber.Ctx) error {
+	_, image, err := s.findUserProjectImage(c, model.RoleViewer)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(GetResponse{
+	return conditionalJSON(c, image.Version, getProjectImageResponse{
 		Image: imageHTTPStruct(image),
 	})
 }
 
 func (s *Server) deleteProjectImage(c *fiber.Ctx) error {
-	userID, err := getUserID(c)
-	if err != nil {
-		return newValidationError("valid user_id is required")
-	}
-
-	projectID, err := uuid.FromString(c.Params("project_id"))
-	if err != nil {
-		return newValidationError("valid project_id is required")
-	}
-
-	imageID, err := uuid.FromString(c.Params("image_id"))
-	if err != nil {
-		return newValidationError("valid image_id is required")
-	}
-
-	user, err := model.FindUserByID(s.db, userID)
+	_, image, err := s.findUserProjectImage(c, model.RoleEditor)
 	if err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
-	if err != nil {
+	if err := checkIfMatch(c, image.Version); err != nil {
 		return err
 	}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
-	}
-
-	image, err := model.FindImageByID(s.db, imageID)
-	if err != nil {
-		return nil
-	}
-
-	// first delete image from s3, then delete from DB
 	s3Client, err := s.s3Client()
 	if err != nil {
 		return err
 	}
 
-	_, err = s3Client.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: &s.config.S3.ImageBucket,
-		Key:    aws.String(strings.TrimPrefix(image.URL, s3BucketURL(s.config.S3.ImageBucket))),
+	// the row goes first, so a stale version leaves the object alone, and
+	// it is only deleted for good once the object is gone too
+	conditional := c.Get(fiber.HeaderIfMatch) != ""
+	err = database.Transact(c.Context(), s.db, func(ctx context.Context, tx *gorm.DB) error {
+		var err error
+		if conditional {
+			err = model.DeleteImageVersion(tx, image.ID, image.Version)
+		} else {
+			err = model.DeleteImageByID(tx, image.ID)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = s3Client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: &s.config.S3.ImageBucket,
+			Key:    aws.String(strings.TrimPrefix(image.URL, s3BucketURL(s.config.S3.ImageBucket))),
+		})
+		return err
 	})
 	if err != nil {
 		return err
 	}
 
-	if err := model.DeleteImageByID(s.db, image.ID); err != nil {
-		return err
-	}
-
 	c.Status(200).Send()
 	return nil
 }
 
-func (s *Server) startProjectJob(c *fiber.Ctx) error {
-	type PostResponse struct {
-		JobID  string `json:"jobId"`
-		Status string `json:"status"`
-	}
+type startProjectJobRequest struct {
+	Priority int                    `json:"priority"`
+	Options  map[string]interface{} `json:"options"`
+}
 
-	userID, err := getUserID(c)
-	if err != nil {
-		return newValidationError("valid user_id is required")
+type startProjectJobResponse struct {
+	JobID         string `json:"jobId"`
+	Status        string `json:"status"`
+	QueuePosition int    `json:"queuePosition,omitempty"`
+}
+
+// startProjectJob queues a new job for the project. If the concurrency limits
+// allow it the job is scheduled right away, otherwise it stays queued and its
+// position in the queue is returned. Options for the model can be given in
+// the request body, they are passed as is to the worker.
+func (s *Server) startProjectJob(c *fiber.Ctx) error {
+	// the body is optional, older clients do not send one
+	var req startProjectJobRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
 	}
 
-	projectID, err := uuid.FromString(c.Params("project_id"))
+	err := validate.New().
+		Field("priority", req.Priority, validate.Range(minJobPriority, maxJobPriority)).
+		Err()
 	if err != nil {
-		return newValidationError("valid project_id is required")
+		return err
 	}
 
-	user, err := model.FindUserByID(s.db, userID)
+	_, project, err := s.authorizeProject(c, model.RoleEditor)
 	if err != nil {
 		return err
 	}
 
-	project, err := model.FindProjectByID(s.db, projectID)
-	if err != nil {
+	// make sure there is no job running already, finished or failed jobs
+	// do not prevent the project from running again.
+	job, err := model.FindJobForProject(s.db, project.ID)
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
 		return err
 	}
 
-	if project.UserID != user.ID {
-		return newNotFoundError("project not found")
+	if job != nil && !job.IsFinal() {
+		return apierror.New(
+			http.StatusBadRequest,
+			apierror.CodeJobAlreadyExists,
+			"job already exists for this project",
+		)
 	}
 
-	// make sure there is no jobs already
-	job, err := model.FindJobForProject(s.db, projectID)
-	if err != nil && !gorm.IsRecordNotFoundError(err) {
+	// the keywords and images are recorded along with the job, so it is
+	// known what the job processed even if the project changes later on.
+	var newJob *model.Job
+	err = database.Transact(c.Context(), s.db, func(ctx context.Context, tx *gorm.DB) error {
+		params, err := model.NewJobParams(tx, project, req.Options)
+		if err != nil {
+			return err
+		}
+
+		newJob, err = model.CreateNewJob(tx, project.ID, req.Priority, params)
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
-	if job != nil {
-		return newValidationError("job already exists for this project")
+	if _, err := s.scheduler.Schedule(c.Context()); err != nil {
+		return err
 	}
 
-	newJob, err := model.CreateNewJob(s.db, projectID)
+	// the job may or may not have been scheduled
+	newJob, err = model.FindJobByID(s.db, newJob.ID)
 	if err != nil {
-		return nil
+		return notFound(err, "job")
 	}
 
-	return c.Status(201).JSON(PostResponse{
+	res := startProjectJobResponse{
 		Status: newJob.Status,
 		JobID:  newJob.ID.String(),
-	})
+	}
+	if newJob.Status == model.JobStatusQueued {
+		res.QueuePosition, err = model.QueuePosition(s.db, newJob)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Status(http.StatusCreated).JSON(res)
 }
 
 func s3BucketURL(bucketName string) string {
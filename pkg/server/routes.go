@@ -0,0 +1,316 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+const apiPrefix = "/api/v1"
+
+// route describes an endpoint of the API. The same table registers the
+// routes and generates the OpenAPI document, so the two never drift apart.
+type route struct {
+	method  string
+	path    string
+	handler Handler
+	summary string
+	tag     string
+
+	// public routes are reachable without a token
+	public bool
+
+	// idempotent routes replay their response for retries carrying the
+	// same Idempotency-Key header
+	idempotent bool
+
+	// conditional routes send an ETag and honor If-Match on writes and
+	// If-None-Match on reads
+	conditional bool
+
+	params []param
+
+	// request is the JSON body, nil when there is none
+	request interface{}
+
+	// upload is the multipart field holding the uploaded files
+	upload string
+
+	// status of the success response, 200 when not set
+	status int
+
+	// noContent tells when the route responds with 204 No Content instead,
+	// empty when it never does
+	noContent string
+
+	// response is the JSON body of the success response, nil when there is
+	// none or it is not JSON, in which case contentType is set
+	response    interface{}
+	contentType string
+}
+
+// param is a query or header parameter of a route, path parameters are
+// taken from the path itself.
+type param struct {
+	name        string
+	in          string
+	description string
+	required    bool
+	kind        string
+	enum        []string
+	multi       bool
+}
+
+var pageParams = []param{
+	{name: "limit", in: "query", kind: "integer", description: "max number of items returned, up to 200"},
+	{name: "cursor", in: "query", kind: "string", description: "nextCursor of the previous page"},
+	{name: "sort", in: "query", kind: "string", enum: []string{"-created_at", "created_at"}},
+}
+
+var sharedParams = []param{
+	{name: headerSharePassword, in: "header", kind: "string", description: "password of protected share links"},
+}
+
+var createdParams = []param{
+	{name: "created_after", in: "query", kind: "date-time"},
+	{name: "created_before", in: "query", kind: "date-time"},
+}
+
+func withParams(groups ...[]param) []param {
+	var params []param
+	for _, g := range groups {
+		params = append(params, g...)
+	}
+	return params
+}
+
+func (s *Server) routes() []route {
+	return []route{
+		{
+			method: fiber.MethodGet, path: "/openapi.json", handler: s.getOpenAPI, public: true,
+			summary: "OpenAPI document of the API", tag: "docs",
+			contentType: fiber.MIMEApplicationJSON,
+		},
+		{
+			method: fiber.MethodGet, path: "/docs", handler: s.getDocs, public: true,
+			summary: "Interactive API documentation", tag: "docs",
+			contentType: fiber.MIMETextHTML,
+		},
+		{
+			method: fiber.MethodPost, path: "/auth/register", handler: s.register, public: true,
+			summary: "Register a new user", tag: "auth",
+			request: registerRequest{}, status: http.StatusCreated, response: registerResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/auth/login", handler: s.login, public: true,
+			summary: "Log in and get a token", tag: "auth",
+			request: loginRequest{}, response: loginResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/shared/:token", handler: s.getSharedProject, public: true,
+			summary: "Get the project of a share link", tag: "shared",
+			params: sharedParams, response: getSharedProjectResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/shared/:token/images", handler: s.getSharedImages, public: true,
+			summary: "List the images of the project of a share link", tag: "shared",
+			params:   withParams(sharedParams, createdParams, pageParams),
+			response: getProjectImagesResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/shared/:token/images/:image_id", handler: s.getSharedImage, public: true,
+			summary: "Get an image of the project of a share link", tag: "shared",
+			params: sharedParams, response: getProjectImageResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/images", handler: s.searchImages,
+			summary: "Search the images of every project by label", tag: "images",
+			params: withParams([]param{
+				{name: "label", in: "query", kind: "string", multi: true, required: true},
+				{name: "match", in: "query", kind: "string", enum: []string{"all", "any"}},
+			}, pageParams),
+			response: searchImagesResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/projects", handler: s.createProject,
+			summary: "Create a project", tag: "projects", idempotent: true,
+			request: createProjectRequest{}, status: http.StatusCreated, response: createProjectResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects", handler: s.getProjects,
+			summary: "List projects", tag: "projects",
+			params: withParams([]param{
+				{name: "name", in: "query", kind: "string", description: "name prefix"},
+				{name: "keyword", in: "query", kind: "string"},
+				{name: "status", in: "query", kind: "string", description: "status of the latest job", enum: []string{
+					model.JobStatusQueued,
+					model.JobStatusPendingLabels,
+					model.JobStatusProcessing,
+					model.JobStatusFinished,
+					model.JobStatusFailed,
+				}},
+			}, createdParams, pageParams),
+			response: getProjectsResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id", handler: s.getProject,
+			summary: "Get a project", tag: "projects", conditional: true,
+			response: getProjectResponse{},
+		},
+		{
+			method: fiber.MethodPut, path: "/users/:user_id/projects/:project_id", handler: s.replaceProject,
+			summary: "Replace the editable fields of a project", tag: "projects", conditional: true,
+			request: replaceProjectRequest{}, response: updateProjectResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/users/:user_id/projects/:project_id", handler: s.patchProject,
+			summary: "Update a project with a JSON Merge Patch", tag: "projects", conditional: true,
+			request: patchProjectRequest{}, response: updateProjectResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/users/:user_id/projects/:project_id", handler: s.deleteProject,
+			summary: "Delete a project", tag: "projects", conditional: true,
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/members", handler: s.getProjectMembers,
+			summary: "List the members of a project", tag: "members",
+			response: getProjectMembersResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/projects/:project_id/members", handler: s.addProjectMember,
+			summary: "Give a user a role on a project", tag: "members", idempotent: true,
+			request: addProjectMemberRequest{}, status: http.StatusCreated, response: addProjectMemberResponse{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/users/:user_id/projects/:project_id/members/:member_id", handler: s.updateProjectMember,
+			summary: "Change the role of a member", tag: "members",
+			request: updateProjectMemberRequest{}, response: updateProjectMemberResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/users/:user_id/projects/:project_id/members/:member_id", handler: s.removeProjectMember,
+			summary: "Remove a member from a project, or leave it", tag: "members",
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/shares", handler: s.getShareLinks,
+			summary: "List the share links of a project", tag: "shares",
+			response: getShareLinksResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/projects/:project_id/shares", handler: s.createShareLink,
+			summary: "Create a public read-only link to a project", tag: "shares", idempotent: true,
+			request: createShareLinkRequest{}, status: http.StatusCreated, response: createShareLinkResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/users/:user_id/projects/:project_id/shares/:share_id", handler: s.revokeShareLink,
+			summary: "Revoke a share link", tag: "shares",
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/stats", handler: s.getProjectStats,
+			summary: "Label statistics and keyword matches of a project", tag: "projects",
+			response: getProjectStatsResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/annotations", handler: s.getProjectAnnotations,
+			summary: "Export the annotations of a project as COCO JSON or Pascal VOC", tag: "projects",
+			params: []param{
+				{name: "format", in: "query", kind: "string", enum: []string{"coco", "voc"}},
+			},
+			contentType: fiber.MIMEApplicationJSON + ", application/zip",
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/projects/:project_id/images", handler: s.postProjectImage,
+			summary: "Upload images to a project", tag: "images", idempotent: true,
+			upload: "images", status: http.StatusCreated, response: postProjectImageResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/images", handler: s.getProjectImages,
+			summary: "List the images of a project", tag: "images",
+			params:   withParams(createdParams, pageParams),
+			response: getProjectImagesResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/images/:image_id", handler: s.getProjectImage,
+			summary: "Get an image", tag: "images", conditional: true,
+			response: getProjectImageResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/users/:user_id/projects/:project_id/images/:image_id", handler: s.deleteProjectImage,
+			summary: "Delete an image", tag: "images", conditional: true,
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/images/:image_id/labels", handler: s.getImageLabels,
+			summary: "Get the labels of an image and their edit history", tag: "labels",
+			response: httpImageLabelsHistory{},
+		},
+		{
+			method: fiber.MethodPatch, path: "/users/:user_id/projects/:project_id/images/:image_id/labels", handler: s.patchImageLabels,
+			summary: "Add or remove labels of an image", tag: "labels", conditional: true,
+			request: patchImageLabelsRequest{}, response: httpImageLabelsHistory{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/images/:image_id/masks", handler: s.getImageMasks,
+			summary: "Get the legend of the masks of an image", tag: "masks",
+			response: getImageMasksResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/images/:image_id/masks.png", handler: s.getImageMasksOverlay,
+			summary: "Render every mask of an image as a colored overlay", tag: "masks",
+			contentType: "image/png",
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/images/:image_id/masks/:label.png", handler: s.getImageLabelMask,
+			summary: "Render the mask of a single label", tag: "masks",
+			contentType: "image/png",
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/projects/:project_id/job", handler: s.startProjectJob,
+			summary: "Start a job for a project", tag: "jobs", idempotent: true,
+			request: startProjectJobRequest{}, status: http.StatusCreated, response: startProjectJobResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/jobs/:job_id", handler: s.getJob,
+			summary: "Get a job", tag: "jobs",
+			response: getJobResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/jobs/:job_id/result", handler: s.getJobResult,
+			summary: "Get the result of a job", tag: "jobs",
+			response: getJobResultResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/projects/:project_id/jobs/:job_id/events", handler: s.streamJobEvents,
+			summary: "Stream the events of a job as Server-Sent Events", tag: "jobs",
+			params: []param{
+				{name: "Last-Event-ID", in: "header", kind: "integer", description: "resume after this event"},
+			},
+			contentType: "text/event-stream",
+			noContent:   "the job is done and every event was sent, clients should stop reconnecting",
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/webhooks", handler: s.createWebhook,
+			summary: "Register a webhook", tag: "webhooks", idempotent: true,
+			request: createWebhookRequest{}, status: http.StatusCreated, response: createWebhookResponse{},
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/webhooks", handler: s.getWebhooks,
+			summary: "List webhooks", tag: "webhooks",
+			response: getWebhooksResponse{},
+		},
+		{
+			method: fiber.MethodDelete, path: "/users/:user_id/webhooks/:webhook_id", handler: s.deleteWebhook,
+			summary: "Delete a webhook", tag: "webhooks",
+		},
+		{
+			method: fiber.MethodGet, path: "/users/:user_id/webhooks/:webhook_id/deliveries", handler: s.getWebhookDeliveries,
+			summary: "List the deliveries of a webhook", tag: "webhooks",
+			response: getWebhookDeliveriesResponse{},
+		},
+		{
+			method: fiber.MethodPost, path: "/users/:user_id/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", handler: s.redeliverWebhookDelivery,
+			summary: "Send a delivery again", tag: "webhooks", idempotent: true,
+			status: http.StatusAccepted, response: redeliverWebhookDeliveryResponse{},
+		},
+	}
+}
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+	"net"
+
 	"github.com/gofiber/cors"
 	"github.com/gofiber/fiber"
 	"github.com/gofiber/fiber/middleware"
@@ -8,12 +11,18 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"github.com/caquillo07/pyvinci-server/pkg/conf"
+	"github.com/caquillo07/pyvinci-server/pkg/events"
+	"github.com/caquillo07/pyvinci-server/pkg/jobs"
+	"github.com/caquillo07/pyvinci-server/pkg/webhook"
 )
 
 type Server struct {
 	app    *fiber.App
 	config *conf.Config
 	db     *gorm.DB
+	events *events.Broker
+
+	scheduler *jobs.Scheduler
 }
 
 type Handler func(c *fiber.Ctx) error
@@ -23,6 +32,9 @@ func NewServer(config *conf.Config, db *gorm.DB) *Server {
 		app:    fiber.New(),
 		config: config,
 		db:     db,
+		events: events.NewBroker(),
+
+		scheduler: jobs.NewScheduler(db, config.Jobs),
 	}
 
 	srv.applyMiddleware()
@@ -30,7 +42,13 @@ func NewServer(config *conf.Config, db *gorm.DB) *Server {
 	return srv
 }
 
+// Serve runs the background tasks of the server and serves the API on the
+// configured port until it fails.
 func (s *Server) Serve() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
 	port := 3000
 	if s.config.REST.Port != 0 {
 		port = s.config.REST.Port
@@ -39,11 +57,37 @@ func (s *Server) Serve() error {
 	return s.app.Listen(port)
 }
 
+// Start runs the background tasks of the server until the context is
+// cancelled: the database listener publishing the job and image events, the
+// webhook dispatcher, the job reaper and scheduler, and the sweeping of
+// expired idempotency keys.
+func (s *Server) Start(ctx context.Context) {
+	go events.NewListener(s.config.Database.ConnectionString, s.db, s.events).Run(ctx)
+	go webhook.NewDispatcher(s.db, s.events).Run(ctx)
+	go jobs.NewReaper(s.db, s.config.Jobs).Run(ctx)
+	go s.scheduler.Run(ctx, s.events)
+	go s.sweepIdempotencyKeys(ctx)
+}
+
+// Listener serves the API on ln until Shutdown is called. Unlike Serve, the
+// background tasks are left to Start.
+func (s *Server) Listener(ln net.Listener) error {
+	return s.app.Listener(ln)
+}
+
+// Shutdown stops serving the API, waiting for the requests in progress.
+func (s *Server) Shutdown() error {
+	return s.app.Shutdown()
+}
+
 func (s *Server) applyMiddleware() {
 	s.app.Use(middleware.Logger())
 	s.app.Use(Recover())
 	s.app.Use(middleware.RequestID())
-	s.app.Use(cors.New())
+	s.app.Use(cors.New(cors.Config{
+		// browsers only let clients read the ETag when exposed
+		ExposeHeaders: []string{fiber.HeaderETag},
+	}))
 	// Custom error handler
 	s.app.Settings.ErrorHandler = errorHandler
 }
@@ -52,22 +96,35 @@ func (s *Server) applyRoutes() {
 	s.app.Get("/", func(c *fiber.Ctx) {
 		c.Send("Hello World!")
 	})
-	v1Api := s.app.Group("/api/v1")
-	v1Api.Post("/auth/register", handler(s.register))
-	v1Api.Post("/auth/login", handler(s.login))
+	v1Api := s.app.Group(apiPrefix)
+
+	// public routes go first, everything after the protected middleware
+	// requires a token
+	routes := s.routes()
+	for _, r := range routes {
+		if r.public {
+			v1Api.Add(r.method, r.path, s.routeHandlers(r)...)
+		}
+	}
 
 	// protected endpoints
 	v1Api.Use(s.protected())
-	v1Api.Post("/users/:user_id/projects", handler(s.createProject))
-	v1Api.Get("/users/:user_id/projects", handler(s.getProjects))
-	v1Api.Get("/users/:user_id/projects/:project_id", handler(s.getProject))
-	v1Api.Put("/users/:user_id/projects/:project_id", handler(s.updateProject))
-	v1Api.Delete("/users/:user_id/projects/:project_id", handler(s.deleteProject))
-	v1Api.Post("/users/:user_id/projects/:project_id/images", handler(s.postProjectImage))
-	v1Api.Get("/users/:user_id/projects/:project_id/images", handler(s.getProjectImages))
-	v1Api.Get("/users/:user_id/projects/:project_id/images/:image_id", handler(s.getProjectImage))
-	v1Api.Delete("/users/:user_id/projects/:project_id/images/:image_id", handler(s.deleteProjectImage))
-	v1Api.Post("/users/:user_id/projects/:project_id/job", handler(s.startProjectJob))
+	for _, r := range routes {
+		if !r.public {
+			v1Api.Add(r.method, r.path, s.routeHandlers(r)...)
+		}
+	}
+}
+
+func (s *Server) routeHandlers(r route) []fiber.Handler {
+	var handlers []fiber.Handler
+	if !r.public {
+		handlers = append(handlers, s.authorizeUser())
+	}
+	if r.idempotent {
+		handlers = append(handlers, s.idempotent())
+	}
+	return append(handlers, handler(r.handler))
 }
 
 // handler is a wrapper that allows the the server route functions to return
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+)
+
+const (
+	// random bytes of a share token, 32 characters once encoded
+	shareTokenSize = 24
+
+	// header carrying the password of protected share links
+	headerSharePassword = "X-Share-Password"
+
+	// wrong passwords in a row after which a link is locked, and for how
+	// long
+	maxSharePasswordAttempts = 5
+	sharePasswordLockout     = 15 * time.Minute
+)
+
+type httpShareLink struct {
+	ID             string     `json:"id"`
+	ProjectID      string     `json:"projectId"`
+	CreatedBy      string     `json:"createdBy"`
+	Token          string     `json:"token"`
+	Path           string     `json:"path"`
+	HasPassword    bool       `json:"hasPassword"`
+	Active         bool       `json:"active"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	LockedUntil    *time.Time `json:"lockedUntil,omitempty"`
+	AccessCount    int64      `json:"accessCount"`
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func shareLinkHTTPStruct(l *model.ShareLink) *httpShareLink {
+	return &httpShareLink{
+		ID:             l.ID.String(),
+		ProjectID:      l.ProjectID.String(),
+		CreatedBy:      l.CreatedBy.String(),
+		Token:          l.Token,
+		Path:           apiPrefix + "/shared/" + l.Token,
+		HasPassword:    l.Password != nil,
+		Active:         l.IsActive(time.Now()),
+		ExpiresAt:      l.ExpiresAt,
+		RevokedAt:      l.RevokedAt,
+		LockedUntil:    l.LockedUntil,
+		AccessCount:    l.AccessCount,
+		LastAccessedAt: l.LastAccessedAt,
+		CreatedAt:      l.CreatedAt,
+	}
+}
+
+// httpSharedProject is what share links show of a project, leaving out its
+// owner and the details of its job.
+type httpSharedProject struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	Labels         []string  `json:"labels"`
+	Status         string    `json:"status"`
+	ResultImageURL string    `json:"resultImageUrl,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type createShareLinkRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Password  string     `json:"password"`
+}
+
+type createShareLinkResponse struct {
+	Share *httpShareLink `json:"share"`
+}
+
+// createShareLink creates a link giving read-only access to the project to
+// anyone, only owners can do so. Links can expire and be protected by a
+// password.
+func (s *Server) createShareLink(c *fiber.Ctx) error {
+	var req createShareLinkRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	v := validate.New().
+		Field("password", req.Password, validate.Length(minPasswordLength, maxPasswordLength))
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		v.AddError("expiresAt", "must be in the future")
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	user, project, err := s.authorizeProject(c, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return err
+	}
+
+	link := &model.ShareLink{
+		ProjectID: project.ID,
+		CreatedBy: user.ID,
+		Token:     token,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if req.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+		if err != nil {
+			return err
+		}
+		password := string(hashed)
+		link.Password = &password
+	}
+
+	if err := model.CreateShareLink(s.db, link); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(createShareLinkResponse{
+		Share: shareLinkHTTPStruct(link),
+	})
+}
+
+type getShareLinksResponse struct {
+	Shares []*httpShareLink `json:"shares"`
+}
+
+func (s *Server) getShareLinks(c *fiber.Ctx) error {
+	_, project, err := s.authorizeProject(c, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	links, err := model.AllShareLinksForProject(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	res := getShareLinksResponse{
+		Shares: make([]*httpShareLink, len(links)),
+	}
+	for i, l := range links {
+		res.Shares[i] = shareLinkHTTPStruct(l)
+	}
+	return c.JSON(res)
+}
+
+// revokeShareLink stops a link from granting access. The link is kept so
+// its access count can still be looked at.
+func (s *Server) revokeShareLink(c *fiber.Ctx) error {
+	shareID, err := uuid.FromString(c.Params("share_id"))
+	if err != nil {
+		return newValidationError("valid share_id is required")
+	}
+
+	_, project, err := s.authorizeProject(c, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	link, err := model.FindShareLinkByID(s.db, shareID)
+	if err != nil {
+		return notFound(err, "share")
+	}
+
+	if link.ProjectID != project.ID {
+		return newNotFoundError("share")
+	}
+
+	if err := model.RevokeShareLink(s.db, link); err != nil {
+		return err
+	}
+
+	c.Status(200).Send()
+	return nil
+}
+
+// findSharedProject loads the share link in the request path and its
+// project, checking the password of the link when it has one. Unknown,
+// revoked and expired links are all reported as missing. Links are locked
+// for a while after too many wrong passwords, without checking the ones
+// sent meanwhile.
+func (s *Server) findSharedProject(c *fiber.Ctx) (*model.ShareLink, *model.Project, error) {
+	link, err := model.FindActiveShareLink(s.db, c.Params("token"))
+	if err != nil {
+		return nil, nil, notFound(err, "share")
+	}
+
+	if link.Password != nil {
+		if err := s.checkSharePassword(c, link); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	project, err := model.FindProjectByID(s.db, link.ProjectID)
+	if err != nil {
+		return nil, nil, notFound(err, "share")
+	}
+	return link, project, nil
+}
+
+func (s *Server) checkSharePassword(c *fiber.Ctx, link *model.ShareLink) error {
+	password := c.Get(headerSharePassword)
+	if password == "" {
+		return apierror.New(
+			http.StatusUnauthorized,
+			apierror.CodeSharePasswordRequired,
+			"share link is protected, send its password in the "+headerSharePassword+" header",
+		)
+	}
+
+	if link.IsLocked(time.Now()) {
+		return shareLockedError(c, link)
+	}
+
+	if !link.VerifyPassword(password) {
+		err := model.RecordShareLinkFailedAttempt(s.db, link, maxSharePasswordAttempts, sharePasswordLockout)
+		if err != nil {
+			return err
+		}
+		if link.IsLocked(time.Now()) {
+			return shareLockedError(c, link)
+		}
+		return apierror.New(
+			http.StatusUnauthorized,
+			apierror.CodeInvalidSharePassword,
+			"share link password is not valid",
+		)
+	}
+
+	return model.ResetShareLinkFailedAttempts(s.db, link)
+}
+
+func shareLockedError(c *fiber.Ctx, link *model.ShareLink) error {
+	wait := time.Until(*link.LockedUntil)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())+1))
+	return apierror.New(
+		http.StatusTooManyRequests,
+		apierror.CodeShareLocked,
+		"too many wrong passwords, share link is locked for now",
+	)
+}
+
+type getSharedProjectResponse struct {
+	Project *httpSharedProject `json:"project"`
+}
+
+// getSharedProject returns the project of a share link, along with its
+// labels and result image. Visits start here, so this is the only route
+// counting as an access to the link; the images of the project are not.
+func (s *Server) getSharedProject(c *fiber.Ctx) error {
+	link, project, err := s.findSharedProject(c)
+	if err != nil {
+		return err
+	}
+
+	// a failed count is no reason to turn the visitor away
+	if err := model.RecordShareLinkAccess(s.db, link); err != nil {
+		zap.L().Error(
+			"failed to record share link access",
+			zap.String("share_id", link.ID.String()),
+			zap.Error(err),
+		)
+	}
+
+	res := &httpSharedProject{
+		ID:        project.ID.String(),
+		Name:      project.Name,
+		CreatedAt: project.CreatedAt,
+	}
+
+	job, err := model.FindJobForProject(s.db, project.ID)
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	if job != nil {
+		res.Status = job.Status
+		if job.ResultImageURL != nil {
+			res.ResultImageURL = *job.ResultImageURL
+		}
+	}
+
+	res.Labels, err = model.AllLabelsForProject(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(getSharedProjectResponse{
+		Project: res,
+	})
+}
+
+// getSharedImages returns a page of the images of the project of a share
+// link, with their labels.
+func (s *Server) getSharedImages(c *fiber.Ctx) error {
+	_, project, err := s.findSharedProject(c)
+	if err != nil {
+		return err
+	}
+	return s.sendProjectImages(c, project)
+}
+
+func (s *Server) getSharedImage(c *fiber.Ctx) error {
+	imageID, err := uuid.FromString(c.Params("image_id"))
+	if err != nil {
+		return newValidationError("valid image_id is required")
+	}
+
+	_, project, err := s.findSharedProject(c)
+	if err != nil {
+		return err
+	}
+
+	image, err := model.FindImageByID(s.db, imageID)
+	if err != nil {
+		return notFound(err, "image")
+	}
+
+	if image.ProjectID != project.ID {
+		return newNotFoundError("image")
+	}
+
+	return c.JSON(getProjectImageResponse{
+		Image: imageHTTPStruct(image),
+	})
+}
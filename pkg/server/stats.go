@@ -0,0 +1,81 @@
+package server
+
+import (
+	"github.com/gofiber/fiber"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+type httpLabelStat struct {
+	Label       string `json:"label"`
+	Occurrences int    `json:"occurrences"`
+	Images      int    `json:"images"`
+}
+
+type httpKeywordMatch struct {
+	Keyword string `json:"keyword"`
+	Images  int    `json:"images"`
+	Matched bool   `json:"matched"`
+}
+
+type getProjectStatsResponse struct {
+	Images          int                 `json:"images"`
+	Labels          []*httpLabelStat    `json:"labels"`
+	Keywords        []*httpKeywordMatch `json:"keywords"`
+	UnmatchedImages []string            `json:"unmatchedImages"`
+}
+
+// getProjectStats reports how often each label was found on the project
+// images, and how the labels relate to the project keywords: which keywords
+// matched at least one image, and which images matched no keyword.
+func (s *Server) getProjectStats(c *fiber.Ctx) error {
+	_, project, err := s.authorizeProject(c, model.RoleViewer)
+	if err != nil {
+		return err
+	}
+
+	imageCount, err := model.CountImagesForProject(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	labels, err := model.ProjectLabelStats(s.db, project.ID)
+	if err != nil {
+		return err
+	}
+
+	keywords, err := model.ProjectKeywordMatches(s.db, project.ID, project.Keywords)
+	if err != nil {
+		return err
+	}
+
+	unmatched, err := model.ImagesMatchingNoKeyword(s.db, project.ID, project.Keywords)
+	if err != nil {
+		return err
+	}
+
+	res := getProjectStatsResponse{
+		Images:          imageCount,
+		Labels:          make([]*httpLabelStat, len(labels)),
+		Keywords:        make([]*httpKeywordMatch, len(keywords)),
+		UnmatchedImages: make([]string, len(unmatched)),
+	}
+	for i, l := range labels {
+		res.Labels[i] = &httpLabelStat{
+			Label:       l.Label,
+			Occurrences: l.Occurrences,
+			Images:      l.Images,
+		}
+	}
+	for i, k := range keywords {
+		res.Keywords[i] = &httpKeywordMatch{
+			Keyword: k.Keyword,
+			Images:  k.Images,
+			Matched: k.Images > 0,
+		}
+	}
+	for i, id := range unmatched {
+		res.UnmatchedImages[i] = id.String()
+	}
+	return c.JSON(res)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber"
+	"github.com/gofrs/uuid"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+	"github.com/caquillo07/pyvinci-server/pkg/validate"
+	"github.com/caquillo07/pyvinci-server/pkg/webhook"
+)
+
+type httpWebhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	ProjectID string    `json:"projectId,omitempty"`
+	URL       string    `json:"url"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type httpWebhookDelivery struct {
+	ID            string     `json:"id"`
+	WebhookID     string     `json:"webhookId"`
+	JobID         string     `json:"jobId"`
+	Event         string     `json:"event"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	ResponseCode  *int       `json:"responseCode,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+func webhookHTTPStruct(w *model.Webhook) *httpWebhook {
+	res := &httpWebhook{
+		ID:        w.ID.String(),
+		UserID:    w.UserID.String(),
+		URL:       w.URL,
+		Active:    w.Active,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+	if w.ProjectID != nil {
+		res.ProjectID = w.ProjectID.String()
+	}
+	return res
+}
+
+func webhookDeliveryHTTPStruct(d *model.WebhookDelivery) *httpWebhookDelivery {
+	return &httpWebhookDelivery{
+		ID:            d.ID.String(),
+		WebhookID:     d.WebhookID.String(),
+		JobID:         d.JobID.String(),
+		Event:         d.Event,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		ResponseCode:  d.ResponseCode,
+		Error:         d.Error,
+		NextAttemptAt: d.NextAttemptAt,
+		DeliveredAt:   d.DeliveredAt,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
+
+const (
+	minWebhookSecretLength = 16
+	maxWebhookSecretLength = 256
+)
+
+type createWebhookRequest struct {
+	URL       string `json:"url"`
+	ProjectID string `json:"projectId"`
+	Secret    string `json:"secret"`
+}
+
+type createWebhookResponse struct {
+	Webhook *httpWebhook `json:"webhook"`
+	Secret  string       `json:"secret"`
+}
+
+// createWebhook registers a new webhook for the user. When a project is given
+// the webhook is only called for that project's jobs, otherwise it is called
+// for all of them. The secret used to sign the deliveries is only returned
+// here.
+func (s *Server) createWebhook(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return newValidationError("valid user_id is required")
+	}
+
+	var req createWebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	v := validate.New().
+		Field("url", req.URL, validate.Required(), validate.URL("http", "https")).
+		Field("projectId", req.ProjectID, validate.UUID()).
+		Field("secret", req.Secret, validate.Length(minWebhookSecretLength, maxWebhookSecretLength))
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if err := webhook.CheckURL(c.Context(), req.URL); err != nil {
+		v.AddError("url", "must point to a public address")
+		return v.Err()
+	}
+
+	user, err := model.FindUserByID(s.db, userID)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	newWebhook := &model.Webhook{
+		UserID: user.ID,
+		URL:    req.URL,
+		Secret: req.Secret,
+		Active: true,
+	}
+
+	if req.ProjectID != "" {
+		project, err := model.FindProjectByID(s.db, uuid.FromStringOrNil(req.ProjectID))
+		if err != nil {
+			return notFound(err, "project")
+		}
+
+		// members can be notified of the jobs of any project they can view
+		if _, err := model.FindProjectMember(s.db, project.ID, user.ID); err != nil {
+			return notFound(err, "project")
+		}
+		newWebhook.ProjectID = &project.ID
+	}
+
+	if newWebhook.Secret == "" {
+		newWebhook.Secret, err = webhook.NewSecret()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := model.CreateWebhook(s.db, newWebhook); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(createWebhookResponse{
+		Webhook: webhookHTTPStruct(newWebhook),
+		Secret:  newWebhook.Secret,
+	})
+}
+
+type getWebhooksResponse struct {
+	Webhooks []*httpWebhook `json:"webhooks"`
+}
+
+func (s *Server) getWebhooks(c *fiber.Ctx) error {
+	userID, err := getUserID(c)
+	if err != nil {
+		return newValidationError("valid user_id is required")
+	}
+
+	user, err := model.FindUserByID(s.db, userID)
+	if err != nil {
+		return notFound(err, "user")
+	}
+
+	webhooks, err := model.AllWebhooksForUser(s.db, user.ID)
+	if err != nil {
+		return err
+	}
+
+	res := getWebhooksResponse{
+		Webhooks: make([]*httpWebhook, len(webhooks)),
+	}
+	for i, w := range webhooks {
+		res.Webhooks[i] = webhookHTTPStruct(w)
+	}
+	return c.JSON(res)
+}
+
+func (s *Server) deleteWebhook(c *fiber.Ctx) error {
+	w, err := s.findUserWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	if err := model.DeleteWebhookByID(s.db, w.ID); err != nil {
+		return err
+	}
+
+	c.Status(200).Send()
+	return nil
+}
+
+type getWebhookDeliveriesResponse struct {
+	Deliveries []*httpWebhookDelivery `json:"deliveries"`
+}
+
+// getWebhookDeliveries returns the delivery log of a webhook, newest first.
+func (s *Server) getWebhookDeliveries(c *fiber.Ctx) error {
+	w, err := s.findUserWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := model.AllDeliveriesForWebhook(s.db, w.ID)
+	if err != nil {
+		return err
+	}
+
+	res := getWebhookDeliveriesResponse{
+		Deliveries: make([]*httpWebhookDelivery, len(deliveries)),
+	}
+	for i, d := range deliveries {
+		res.Deliveries[i] = webhookDeliveryHTTPStruct(d)
+	}
+	return c.JSON(res)
+}
+
+type redeliverWebhookDeliveryResponse struct {
+	Delivery *httpWebhookDelivery `json:"delivery"`
+}
+
+// redeliverWebhookDelivery queues a delivery to be sent again right away,
+// regardless of whether it succeeded or failed before.
+func (s *Server) redeliverWebhookDelivery(c *fiber.Ctx) error {
+	w, err := s.findUserWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := uuid.FromString(c.Params("delivery_id"))
+	if err != nil {
+		return newValidationError("valid delivery_id is required")
+	}
+
+	delivery, err := model.FindWebhookDeliveryByID(s.db, deliveryID)
+	if err != nil {
+		return notFound(err, "webhook delivery")
+	}
+
+	if delivery.WebhookID != w.ID {
+		return newNotFoundError("webhook delivery")
+	}
+
+	now := time.Now()
+	delivery.Status = model.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	if err := delivery.Update(s.db); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusAccepted).JSON(redeliverWebhookDeliveryResponse{
+		Delivery: webhookDeliveryHTTPStruct(delivery),
+	})
+}
+
+// findUserWebhook loads the webhook in the request path, making sure it
+// belongs to the user in the path.
+func (s *Server) findUserWebhook(c *fiber.Ctx) (*model.Webhook, error) {
+	userID, err := getUserID(c)
+	if err != nil {
+		return nil, newValidationError("valid user_id is required")
+	}
+
+	webhookID, err := uuid.FromString(c.Params("webhook_id"))
+	if err != nil {
+		return nil, newValidationError("valid webhook_id is required")
+	}
+
+	user, err := model.FindUserByID(s.db, userID)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+
+	w, err := model.FindWebhookByID(s.db, webhookID)
+	if err != nil {
+		return nil, notFound(err, "webhook")
+	}
+
+	if w.UserID != user.ID {
+		return nil, newNotFoundError("webhook")
+	}
+	return w, nil
+}
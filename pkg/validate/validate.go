@@ -0,0 +1,218 @@
+// Package validate checks request values against declarative rules,
+// collecting a field error for every value that breaks one.
+//
+//	v := validate.New()
+//	v.Field("name", req.Name, validate.Required(), validate.Length(1, 100))
+//	v.Each("keywords", req.Keywords, validate.Length(1, 50))
+//	if err := v.Err(); err != nil {
+//		return err
+//	}
+//
+// Rules other than Required accept empty values, so optional fields only
+// need Required left out.
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+)
+
+// Rule checks a single value, returning why it is not valid or an empty
+// string when it is.
+type Rule func(value interface{}) string
+
+// Validator collects the errors of the fields it checks.
+type Validator struct {
+	errs []apierror.FieldError
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+// Field checks the value of the named field. Rules run in order and stop at
+// the first one broken, so each field reports a single error.
+func (v *Validator) Field(name string, value interface{}, rules ...Rule) *Validator {
+	for _, rule := range rules {
+		if msg := rule(value); msg != "" {
+			v.AddError(name, msg)
+			break
+		}
+	}
+	return v
+}
+
+// Each checks every element of a slice, reporting errors as name[i].
+func (v *Validator) Each(name string, values []string, rules ...Rule) *Validator {
+	for i, value := range values {
+		v.Field(fmt.Sprintf("%s[%d]", name, i), value, rules...)
+	}
+	return v
+}
+
+// AddError records an error found outside of the rules.
+func (v *Validator) AddError(field, msg string) {
+	v.errs = append(v.errs, apierror.FieldError{Field: field, Message: msg})
+}
+
+// Errors returns the errors collected so far.
+func (v *Validator) Errors() []apierror.FieldError {
+	return v.errs
+}
+
+// Err returns an API error listing every field error, or nil when all the
+// fields are valid.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return apierror.Fields(v.errs...)
+}
+
+// Required fails on blank strings, empty slices and maps, nil pointers and
+// nil UUIDs.
+func Required() Rule {
+	return func(value interface{}) string {
+		if isEmpty(value) {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+// Length bounds the number of characters of a string.
+func Length(min, max int) Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		if n := utf8.RuneCountInString(s); n < min || n > max {
+			return fmt.Sprintf("must be between %d and %d characters", min, max)
+		}
+		return ""
+	}
+}
+
+// Items bounds the number of elements of a slice.
+func Items(min, max int) Rule {
+	return func(value interface{}) string {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice {
+			return ""
+		}
+		if n := rv.Len(); n < min || n > max {
+			return fmt.Sprintf("must have between %d and %d items", min, max)
+		}
+		return ""
+	}
+}
+
+// Range bounds an integer.
+func Range(min, max int) Rule {
+	return func(value interface{}) string {
+		n, ok := value.(int)
+		if !ok {
+			return ""
+		}
+		if n < min || n > max {
+			return fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return ""
+	}
+}
+
+// Match requires a string to match the regular expression, desc tells
+// clients what is expected.
+func Match(re *regexp.Regexp, desc string) Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		if !re.MatchString(s) {
+			return "must be " + desc
+		}
+		return ""
+	}
+}
+
+// OneOf requires a string to be one of the given values.
+func OneOf(values ...string) Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		for _, v := range values {
+			if s == v {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	}
+}
+
+// UUID requires a string to be a valid UUID.
+func UUID() Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		if _, err := uuid.FromString(s); err != nil {
+			return "must be a valid id"
+		}
+		return ""
+	}
+}
+
+// URL requires a string to be an absolute URL with one of the given
+// schemes.
+func URL(schemes ...string) Rule {
+	return func(value interface{}) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		msg := "must be a valid " + strings.Join(schemes, " or ") + " url"
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return msg
+		}
+		for _, scheme := range schemes {
+			if u.Scheme == scheme {
+				return ""
+			}
+		}
+		return msg
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case uuid.UUID:
+		return v == uuid.Nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
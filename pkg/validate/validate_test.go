@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/caquillo07/pyvinci-server/pkg/apierror"
+)
+
+func TestRules(t *testing.T) {
+	var nilProject *struct{}
+	project := &struct{}{}
+	id := uuid.Must(uuid.NewV4())
+	slug := regexp.MustCompile(`^[a-z0-9-]+$`)
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		value interface{}
+		want  string
+	}{
+		{"required string", Required(), "cat", ""},
+		{"required blank string", Required(), "", "is required"},
+		{"required whitespace", Required(), " \t\n", "is required"},
+		{"required nil", Required(), nil, "is required"},
+		{"required uuid", Required(), id, ""},
+		{"required nil uuid", Required(), uuid.Nil, "is required"},
+		{"required slice", Required(), []string{"cat"}, ""},
+		{"required empty slice", Required(), []string{}, "is required"},
+		{"required nil slice", Required(), []string(nil), "is required"},
+		{"required map", Required(), map[string]int{"cat": 1}, ""},
+		{"required empty map", Required(), map[string]int{}, "is required"},
+		{"required pointer", Required(), project, ""},
+		{"required nil pointer", Required(), nilProject, "is required"},
+		{"required zero int", Required(), 0, ""},
+
+		{"length within", Length(1, 5), "cats", ""},
+		{"length too short", Length(3, 5), "ab", "must be between 3 and 5 characters"},
+		{"length too long", Length(1, 3), "cats", "must be between 1 and 3 characters"},
+		{"length counts runes", Length(1, 3), "ñño", ""},
+		{"length counts runes too long", Length(1, 3), "ññññ", "must be between 1 and 3 characters"},
+		{"length skips empty", Length(1, 3), "", ""},
+		{"length skips non strings", Length(1, 3), 12345, ""},
+
+		{"items within", Items(1, 2), []string{"a"}, ""},
+		{"items too few", Items(1, 2), []string{}, "must have between 1 and 2 items"},
+		{"items too many", Items(1, 2), []int{1, 2, 3}, "must have between 1 and 2 items"},
+		{"items skips non slices", Items(1, 2), "abc", ""},
+
+		{"range within", Range(1, 10), 10, ""},
+		{"range below", Range(1, 10), 0, "must be between 1 and 10"},
+		{"range above", Range(1, 10), 11, "must be between 1 and 10"},
+		{"range skips non ints", Range(1, 10), "11", ""},
+
+		{"match", Match(slug, "a slug"), "my-project", ""},
+		{"match fails", Match(slug, "a slug"), "My Project", "must be a slug"},
+		{"match skips empty", Match(slug, "a slug"), "", ""},
+
+		{"one of", OneOf("owner", "viewer"), "viewer", ""},
+		{"one of fails", OneOf("owner", "viewer"), "admin", "must be one of owner, viewer"},
+		{"one of skips empty", OneOf("owner", "viewer"), "", ""},
+
+		{"uuid", UUID(), id.String(), ""},
+		{"uuid fails", UUID(), "not-an-id", "must be a valid id"},
+		{"uuid skips empty", UUID(), "", ""},
+
+		{"url", URL("http", "https"), "https://example.com/hook", ""},
+		{"url other allowed scheme", URL("http", "https"), "http://example.com", ""},
+		{"url scheme not allowed", URL("http", "https"), "ftp://example.com", "must be a valid http or https url"},
+		{"url missing host", URL("https"), "https:///hook", "must be a valid https url"},
+		{"url relative", URL("https"), "/hook", "must be a valid https url"},
+		{"url unparsable", URL("https"), "https://exa mple.com:port", "must be a valid https url"},
+		{"url skips empty", URL("https"), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule(tt.value); got != tt.want {
+				t.Errorf("rule(%#v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(v *Validator)
+		want  []apierror.FieldError
+	}{
+		{
+			name: "valid",
+			check: func(v *Validator) {
+				v.Field("name", "cats", Required(), Length(1, 10))
+				v.Each("keywords", []string{"a", "b"}, Length(1, 10))
+			},
+		},
+		{
+			name: "stops at first broken rule",
+			check: func(v *Validator) {
+				v.Field("name", "", Required(), Length(1, 10))
+				v.Field("slug", "way too long", Length(1, 3), OneOf("a", "b"))
+			},
+			want: []apierror.FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "slug", Message: "must be between 1 and 3 characters"},
+			},
+		},
+		{
+			name: "each names elements",
+			check: func(v *Validator) {
+				v.Each("keywords", []string{"ok", "", "way too long"}, Required(), Length(1, 3))
+			},
+			want: []apierror.FieldError{
+				{Field: "keywords[1]", Message: "is required"},
+				{Field: "keywords[2]", Message: "must be between 1 and 3 characters"},
+			},
+		},
+		{
+			name: "added errors",
+			check: func(v *Validator) {
+				v.Field("name", "cats", Required())
+				v.AddError("expires_at", "must be in the future")
+			},
+			want: []apierror.FieldError{
+				{Field: "expires_at", Message: "must be in the future"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			tt.check(v)
+
+			if got := v.Errors(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Errors() = %v, want %v", got, tt.want)
+			}
+
+			err := v.Err()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+			var apiErr *apierror.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Err() = %v, want an *apierror.Error", err)
+			}
+			if apiErr.Code != apierror.CodeValidationFailed {
+				t.Errorf("Err().Code = %q, want %q", apiErr.Code, apierror.CodeValidationFailed)
+			}
+			if !reflect.DeepEqual(apiErr.Fields, tt.want) {
+				t.Errorf("Err().Fields = %v, want %v", apiErr.Fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+// Package webhook calls the endpoints registered by users when their jobs
+// finish or fail.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/events"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// Events a webhook is called for.
+const (
+	EventJobFinished = "job.finished"
+	EventJobFailed   = "job.failed"
+)
+
+const (
+	// how often the pending deliveries are checked
+	pollInterval = 5 * time.Second
+
+	// how many deliveries are attempted on every poll
+	batchSize = 20
+
+	// how long a claimed delivery is hidden from other servers
+	claimLease = time.Minute
+
+	// timeout of a single delivery request
+	requestTimeout = 10 * time.Second
+
+	// delay before the first retry, doubled after every failed attempt
+	retryBaseDelay = 10 * time.Second
+
+	// deliveries are given up after this many attempts
+	maxAttempts = 8
+)
+
+// Payload is the JSON body sent to webhooks.
+type Payload struct {
+	Event      string    `json:"event"`
+	DeliveryID string    `json:"deliveryId"`
+	Job        Job       `json:"job"`
+	Project    Project   `json:"project"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type Job struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	ResultImageURL string     `json:"resultImageUrl,omitempty"`
+	QueuedAt       time.Time  `json:"queuedAt"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+}
+
+type Project struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+}
+
+// Dispatcher queues a delivery for every webhook interested in a job that
+// finished or failed, and sends the queued deliveries retrying with
+// exponential backoff. The final job events are kept by the database until
+// their deliveries are queued, so none is missed while no server is running.
+type Dispatcher struct {
+	db     *gorm.DB
+	broker *events.Broker
+	client *http.Client
+}
+
+func NewDispatcher(db *gorm.DB, broker *events.Broker) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		broker: broker,
+		client: newClient(),
+	}
+}
+
+// Run dispatches deliveries until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	wake := make(chan struct{}, 1)
+	go d.work(ctx, wake)
+
+	sub := d.broker.Subscribe()
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// the events only save waiting for the next poll, the work is
+			// done in its own goroutine so slow webhooks do not hold up the
+			// subscription
+			if e.Job == nil ||
+				(e.Job.Status != model.JobStatusFinished && e.Job.Status != model.JobStatusFailed) {
+				continue
+			}
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// work queues and attempts the deliveries every poll interval, or sooner
+// when woken up, until the context is cancelled.
+func (d *Dispatcher) work(ctx context.Context, wake <-chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.enqueuePending(ctx)
+		d.deliverDue()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// enqueuePending queues the deliveries of every final job event not handled
+// yet.
+func (d *Dispatcher) enqueuePending(ctx context.Context) {
+	for {
+		n, err := d.enqueueBatch(ctx)
+		if err != nil {
+			zap.L().Error("failed to queue webhook deliveries", zap.Error(err))
+			return
+		}
+		if n < batchSize {
+			return
+		}
+	}
+}
+
+// enqueueBatch queues the deliveries of up to batchSize final job events,
+// returning how many events were handled. The events are only marked as
+// handled along with their deliveries, so a failure leaves them for the next
+// poll.
+func (d *Dispatcher) enqueueBatch(ctx context.Context) (int, error) {
+	var n int
+	err := database.Transact(ctx, d.db, func(ctx context.Context, tx *gorm.DB) error {
+		pending, err := model.ClaimUnqueuedJobEvents(tx, batchSize)
+		if err != nil {
+			return err
+		}
+		n = len(pending)
+
+		for _, e := range pending {
+			if err := enqueue(tx, e); err != nil {
+				return fmt.Errorf("job %s: %w", e.JobID, err)
+			}
+			if err := model.MarkJobEventQueued(tx, e.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// enqueue queues a delivery of the job event for every webhook interested in
+// it.
+func enqueue(db *gorm.DB, e *model.JobEvent) error {
+	var event string
+	switch e.Status {
+	case model.JobStatusFinished:
+		event = EventJobFinished
+	case model.JobStatusFailed:
+		event = EventJobFailed
+	default:
+		return nil
+	}
+
+	job, err := model.FindJobByID(db, e.JobID)
+	if err != nil {
+		return err
+	}
+
+	project, err := model.FindProjectByID(db, job.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := model.ActiveWebhooksForProject(db, project)
+	if err != nil {
+		return err
+	}
+
+	payload := Payload{
+		Event: event,
+		Job: Job{
+			ID:         job.ID.String(),
+			Status:     e.Status,
+			QueuedAt:   job.CreatedAt,
+			StartedAt:  job.StartedAt,
+			FinishedAt: job.FinishedAt,
+		},
+		Project: Project{
+			ID:     project.ID.String(),
+			UserID: project.UserID.String(),
+			Name:   project.Name,
+		},
+	}
+	if job.ResultImageURL != nil {
+		payload.Job.ResultImageURL = *job.ResultImageURL
+	}
+
+	for _, hook := range hooks {
+		delivery := &model.WebhookDelivery{
+			ID:        uuid.Must(uuid.NewV4()),
+			WebhookID: hook.ID,
+			JobID:     job.ID,
+			Event:     event,
+		}
+		payload.DeliveryID = delivery.ID.String()
+		payload.CreatedAt = time.Now()
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		delivery.Payload = string(body)
+
+		if _, err := model.CreateWebhookDelivery(db, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverDue attempts all the deliveries that are due.
+func (d *Dispatcher) deliverDue() {
+	for {
+		deliveries, err := model.ClaimDueWebhookDeliveries(d.db, batchSize, claimLease)
+		if err != nil {
+			zap.L().Error("failed to claim webhook deliveries", zap.Error(err))
+			return
+		}
+
+		for _, delivery := range deliveries {
+			d.attempt(delivery)
+		}
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(delivery *model.WebhookDelivery) {
+	log := zap.L().With(
+		zap.String("delivery_id", delivery.ID.String()),
+		zap.String("webhook_id", delivery.WebhookID.String()),
+		zap.String("event", delivery.Event),
+	)
+
+	hook, err := model.FindWebhookByID(d.db, delivery.WebhookID)
+	if err != nil {
+		log.Error("failed to load webhook", zap.Error(err))
+		return
+	}
+
+	d.deliver(hook, delivery, log)
+	if err := delivery.Update(d.db); err != nil {
+		log.Error("failed to save webhook delivery", zap.Error(err))
+	}
+}
+
+// deliver makes an attempt to send the delivery to the webhook, recording the
+// outcome in the delivery and scheduling the next attempt when it failed.
+func (d *Dispatcher) deliver(hook *model.Webhook, delivery *model.WebhookDelivery, log *zap.Logger) {
+	delivery.Attempts++
+	code, err := d.send(hook, delivery)
+	if code != 0 {
+		delivery.ResponseCode = &code
+	}
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		delivery.Status = model.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.Error = nil
+		log.Info("webhook delivered", zap.Int("response_code", code))
+	case delivery.Attempts >= maxAttempts || errors.Is(err, ErrPrivateAddress):
+		msg := err.Error()
+		delivery.Status = model.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.Error = &msg
+		log.Warn("webhook delivery failed, giving up", zap.Error(err))
+	default:
+		msg := err.Error()
+		next := time.Now().Add(retryDelay(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.Error = &msg
+		log.Info(
+			"webhook delivery failed, will retry",
+			zap.Time("next_attempt_at", next),
+			zap.Error(err),
+		)
+	}
+}
+
+// send posts the delivery payload to the webhook, returning the response
+// status code if one was received.
+func (d *Dispatcher) send(hook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pyvinci-webhooks")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// drain some of the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func retryDelay(attempts int) time.Duration {
+	return retryBaseDelay * time.Duration(math.Pow(2, float64(attempts-1)))
+}
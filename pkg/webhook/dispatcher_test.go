@@ -0,0 +1,297 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// receiver is a webhook endpoint answering with the given statuses in turn,
+// repeating the last one, and keeping the requests it got.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		r.mu.Lock()
+		status := r.statuses[0]
+		if len(r.statuses) > 1 {
+			r.statuses = r.statuses[1:]
+		}
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// testDispatcher is a dispatcher allowed to call the local receivers.
+func testDispatcher(r *receiver) *Dispatcher {
+	return &Dispatcher{client: r.Client()}
+}
+
+func newDelivery() *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:        uuid.Must(uuid.NewV4()),
+		WebhookID: uuid.Must(uuid.NewV4()),
+		Event:     EventJobFinished,
+		Payload:   `{"event":"job.finished"}`,
+		Status:    model.WebhookDeliveryPending,
+	}
+}
+
+// bufferLogger returns a logger writing to the returned buffer.
+func bufferLogger() (*zap.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(&buf),
+		zapcore.DebugLevel,
+	)
+	return zap.New(core), &buf
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	r := newReceiver(t, http.StatusNoContent)
+	hook := &model.Webhook{URL: r.URL + "/hook", Secret: "a very secret secret"}
+	delivery := newDelivery()
+
+	testDispatcher(r).deliver(hook, delivery, zap.NewNop())
+
+	if delivery.Status != model.WebhookDeliveryDelivered || delivery.DeliveredAt == nil {
+		t.Fatalf("delivery status = %s, want %s", delivery.Status, model.WebhookDeliveryDelivered)
+	}
+	if delivery.ResponseCode == nil || *delivery.ResponseCode != http.StatusNoContent {
+		t.Errorf("delivery response code = %v, want %d", delivery.ResponseCode, http.StatusNoContent)
+	}
+	if r.count() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", r.count())
+	}
+
+	req, body := r.requests[0], r.bodies[0]
+	if string(body) != delivery.Payload {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		HeaderEvent:    EventJobFinished,
+		HeaderDelivery: delivery.ID.String(),
+		"User-Agent":   "pyvinci-webhooks",
+	}
+	for key, want := range headers {
+		if got := req.Header.Get(key); got != want {
+			t.Errorf("header %s = %q, want %q", key, got, want)
+		}
+	}
+
+	timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); d < -time.Second || d > time.Minute {
+		t.Errorf("timestamp is %s off", d)
+	}
+	signature := req.Header.Get(HeaderSignature)
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("signature %q has no sha256= prefix", signature)
+	}
+	if !Verify(hook.Secret, timestamp, body, signature) {
+		t.Error("signature does not verify with the webhook secret")
+	}
+	if Verify("another secret", timestamp, body, signature) {
+		t.Error("signature verifies with another secret")
+	}
+	if Verify(hook.Secret, timestamp+1, body, signature) {
+		t.Error("signature verifies with another timestamp")
+	}
+}
+
+func TestDeliverRetriesServerErrors(t *testing.T) {
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	hook := &model.Webhook{URL: r.URL, Secret: "secret"}
+	delivery := newDelivery()
+	d := testDispatcher(r)
+
+	for attempt, wantCode := range []int{http.StatusInternalServerError, http.StatusBadGateway} {
+		log, buf := bufferLogger()
+		start := time.Now()
+		d.deliver(hook, delivery, log)
+
+		if delivery.Attempts != attempt+1 {
+			t.Errorf("attempts = %d, want %d", delivery.Attempts, attempt+1)
+		}
+		if delivery.Status != model.WebhookDeliveryPending {
+			t.Errorf("status after a %d = %s, want %s", wantCode, delivery.Status, model.WebhookDeliveryPending)
+		}
+		if delivery.ResponseCode == nil || *delivery.ResponseCode != wantCode {
+			t.Errorf("response code = %v, want %d", delivery.ResponseCode, wantCode)
+		}
+		if delivery.Error == nil || !strings.Contains(*delivery.Error, strconv.Itoa(wantCode)) {
+			t.Errorf("error = %v, want it to mention %d", delivery.Error, wantCode)
+		}
+
+		wantNext := start.Add(retryDelay(attempt + 1))
+		if delivery.NextAttemptAt == nil ||
+			delivery.NextAttemptAt.Before(wantNext) ||
+			delivery.NextAttemptAt.After(wantNext.Add(time.Second)) {
+			t.Errorf("next attempt at %v, want %v", delivery.NextAttemptAt, wantNext)
+		}
+		if !strings.Contains(buf.String(), "webhook delivery failed, will retry") {
+			t.Errorf("retry was not logged, log: %s", buf)
+		}
+	}
+
+	d.deliver(hook, delivery, zap.NewNop())
+	if delivery.Status != model.WebhookDeliveryDelivered {
+		t.Errorf("status = %s, want %s", delivery.Status, model.WebhookDeliveryDelivered)
+	}
+	if delivery.Error != nil || delivery.NextAttemptAt != nil {
+		t.Errorf("delivered delivery kept error %v and next attempt %v", delivery.Error, delivery.NextAttemptAt)
+	}
+	if r.count() != 3 {
+		t.Errorf("receiver got %d requests, want 3", r.count())
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	r := newReceiver(t, http.StatusServiceUnavailable)
+	delivery := newDelivery()
+	delivery.Attempts = maxAttempts - 1
+
+	log, buf := bufferLogger()
+	testDispatcher(r).deliver(&model.Webhook{URL: r.URL}, delivery, log)
+
+	if delivery.Status != model.WebhookDeliveryFailed {
+		t.Errorf("status = %s, want %s", delivery.Status, model.WebhookDeliveryFailed)
+	}
+	if delivery.NextAttemptAt != nil {
+		t.Errorf("next attempt at %v, want none", delivery.NextAttemptAt)
+	}
+	if !strings.Contains(buf.String(), "giving up") {
+		t.Errorf("giving up was not logged, log: %s", buf)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{maxAttempts - 1, 640 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	delivery := newDelivery()
+
+	// the receiver listens on loopback, which real dispatchers refuse
+	NewDispatcher(nil, nil).deliver(&model.Webhook{URL: r.URL}, delivery, zap.NewNop())
+
+	if r.count() != 0 {
+		t.Errorf("receiver got %d requests, want none", r.count())
+	}
+	if delivery.Status != model.WebhookDeliveryFailed {
+		t.Errorf("status = %s, want %s without retrying", delivery.Status, model.WebhookDeliveryFailed)
+	}
+	if delivery.Error == nil || !strings.Contains(*delivery.Error, ErrPrivateAddress.Error()) {
+		t.Errorf("error = %v, want %v", delivery.Error, ErrPrivateAddress)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"172.32.0.1", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.5.4", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://93.184.216.34/hook", nil},
+		{"http://127.0.0.1:8080/hook", ErrPrivateAddress},
+		{"http://[::1]/hook", ErrPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data", ErrPrivateAddress},
+		{"https://10.0.0.8/hook", ErrPrivateAddress},
+		{"http://localhost:3000/hook", ErrPrivateAddress},
+		// unknown hosts are checked when delivering
+		{"https://webhooks.invalid/hook", nil},
+	}
+	for _, tt := range tests {
+		if got := CheckURL(context.Background(), tt.url); !errors.Is(got, tt.want) {
+			t.Errorf("CheckURL(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
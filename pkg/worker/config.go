@@ -0,0 +1,19 @@
+package worker
+
+import "time"
+
+// Config provides the embedded worker configuration
+type Config struct {
+
+	// Name of the processor jobs are handed to, see NewProcessor
+	Processor string
+
+	// How many jobs are processed at once
+	Concurrency int
+
+	// How often the queue is checked when there is nothing to do
+	PollInterval time.Duration
+
+	// How long a single job may take before it is abandoned and failed
+	JobTimeout time.Duration
+}
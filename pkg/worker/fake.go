@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/caquillo07/pyvinci-server/pkg/mask"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+var (
+	fakeThings = []string{"person", "dog", "cat", "car", "bicycle", "bird", "boat", "chair"}
+	fakeStuff  = []string{"sky", "grass", "road", "water", "wall", "tree", "sand", "snow"}
+)
+
+// size of the masks generated by the fake processor
+const fakeMaskSize = 64
+
+// FakeProcessor is a deterministic stand-in for the real model. The labels
+// and masks of an image are derived from its ID and the job keywords, so the
+// same job always produces the same result. It allows running the whole job
+// lifecycle without the ML stack.
+type FakeProcessor struct{}
+
+func (FakeProcessor) Process(ctx context.Context, input *Input, report func(progress int)) (*Result, error) {
+	res := &Result{
+		Images: make(map[uuid.UUID]model.ImageLabels, len(input.Images)),
+	}
+
+	for i, img := range input.Images {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		seed := sha256.Sum256(append(img.ID.Bytes(), []byte(img.URL)...))
+		things := pickLabels(fakeThings, seed[0:8])
+		stuff := pickLabels(fakeStuff, seed[8:16])
+
+		// every keyword the image "matches" is added as a thing, so the
+		// keyword matching can be exercised too.
+		for j, k := range input.Job.Params.Keywords {
+			if seed[16+j%16]%2 == 0 {
+				things = appendUnique(things, k)
+			}
+		}
+		sort.Strings(things)
+
+		masksLabels := append(append([]string{}, things...), stuff...)
+		masks, err := fakeMasks(len(masksLabels), seed[:])
+		if err != nil {
+			return nil, err
+		}
+		scores := make(model.LabelScores, len(masksLabels))
+		for j, l := range masksLabels {
+			scores[l] = 0.5 + float64(seed[j%len(seed)]%50)/100
+		}
+		res.Images[img.ID] = model.ImageLabels{
+			Things:      things,
+			Stuff:       stuff,
+			MasksLabels: masksLabels,
+			Masks:       masks,
+			Scores:      scores,
+		}
+
+		report((i + 1) * 100 / len(input.Images))
+	}
+
+	if len(input.Images) > 0 {
+		res.ResultImageURL = &input.Images[0].URL
+	}
+	return res, nil
+}
+
+// pickLabels picks between one and three labels of the vocabulary.
+func pickLabels(vocabulary []string, seed []byte) []string {
+	n := 1 + int(seed[0])%3
+	labels := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		labels = appendUnique(labels, vocabulary[int(seed[1+i])%len(vocabulary)])
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// fakeMasks generates n rectangular masks.
+func fakeMasks(n int, seed []byte) ([]byte, error) {
+	masks := make([]*mask.Mask, n)
+	for i := range masks {
+		m := mask.New(fakeMaskSize, fakeMaskSize)
+		x0 := int(seed[i%len(seed)]) % (fakeMaskSize / 2)
+		y0 := int(seed[(i+1)%len(seed)]) % (fakeMaskSize / 2)
+		w := fakeMaskSize/4 + int(seed[(i+2)%len(seed)])%(fakeMaskSize/4)
+		h := fakeMaskSize/4 + int(seed[(i+3)%len(seed)])%(fakeMaskSize/4)
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				m.Set(x, y, true)
+			}
+		}
+		masks[i] = m
+	}
+	return mask.Encode(masks)
+}
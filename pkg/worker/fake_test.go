@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/caquillo07/pyvinci-server/pkg/mask"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+func fakeInput() *Input {
+	input := &Input{
+		Job: &model.Job{
+			Params: model.JobParams{Keywords: []string{"kite", "lamp", "tent"}},
+		},
+	}
+	for _, id := range []string{
+		"4c6e9f0a-8d1b-4d5e-9a57-2f1c0b7e3a11",
+		"b2f0d7c4-3e6a-4f19-8c02-6d5a9e1f7b22",
+		"e9a1c3b5-7d2f-4a68-b0e4-1f3c5d7e9a33",
+	} {
+		input.Images = append(input.Images, &model.Image{
+			ID:  uuid.Must(uuid.FromString(id)),
+			URL: "https://example.com/" + id + ".jpg",
+		})
+	}
+	return input
+}
+
+func TestFakeProcessorIsDeterministic(t *testing.T) {
+	var progress []int
+	first, err := FakeProcessor{}.Process(context.Background(), fakeInput(), func(p int) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := FakeProcessor{}.Process(context.Background(), fakeInput(), func(int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Process() = %+v, then %+v, want the same result", first, second)
+	}
+
+	if want := []int{33, 66, 100}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("reported progress %v, want %v", progress, want)
+	}
+	input := fakeInput()
+	if first.ResultImageURL == nil || *first.ResultImageURL != input.Images[0].URL {
+		t.Errorf("result image URL = %v, want %s", first.ResultImageURL, input.Images[0].URL)
+	}
+	if len(first.Images) != len(input.Images) {
+		t.Fatalf("got labels for %d images, want %d", len(first.Images), len(input.Images))
+	}
+
+	for _, img := range input.Images {
+		labels, ok := first.Images[img.ID]
+		if !ok {
+			t.Errorf("no labels for image %s", img.ID)
+			continue
+		}
+		if len(labels.Things) == 0 || len(labels.Stuff) == 0 {
+			t.Errorf("image %s has things %v and stuff %v, want both", img.ID, labels.Things, labels.Stuff)
+		}
+		masks, err := mask.Decode(labels.Masks)
+		if err != nil {
+			t.Errorf("masks of image %s: %v", img.ID, err)
+			continue
+		}
+		if len(masks) != len(labels.MasksLabels) {
+			t.Errorf("image %s has %d masks for %d mask labels", img.ID, len(masks), len(labels.MasksLabels))
+		}
+		for _, l := range labels.MasksLabels {
+			if _, ok := labels.Scores[l]; !ok {
+				t.Errorf("image %s has no score for %s", img.ID, l)
+			}
+		}
+	}
+}
+
+func TestFakeProcessorCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := FakeProcessor{}.Process(ctx, fakeInput(), func(int) {})
+	if err != context.Canceled || res != nil {
+		t.Errorf("Process() = %v, %v, want %v", res, err, context.Canceled)
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// Processor runs the model over the images of a job. Implementations may
+// call report with a percentage as they make progress.
+type Processor interface {
+	Process(ctx context.Context, input *Input, report func(progress int)) (*Result, error)
+}
+
+// Input is everything a processor needs to know about a job.
+type Input struct {
+	Job     *model.Job
+	Project *model.Project
+
+	// Images are the images recorded in the job parameters, images deleted
+	// since the job was created are left out.
+	Images []*model.Image
+}
+
+// Result is the output of a processed job.
+type Result struct {
+	// URL of the image rendered for the whole project, if any
+	ResultImageURL *string
+
+	// Labels found on each image, by image ID
+	Images map[uuid.UUID]model.ImageLabels
+}
+
+// NewProcessor returns the processor registered under the given name.
+func NewProcessor(name string) (Processor, error) {
+	switch name {
+	case "", "fake":
+		return FakeProcessor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown processor %q", name)
+	}
+}
@@ -0,0 +1,184 @@
+// Package worker processes jobs inside the server binary. Jobs are claimed
+// from the same queue the external workers use, and handed to a Processor.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// errJobChanged is returned when a job was modified by someone else while it
+// was being processed, usually the reaper re-queuing it.
+var errJobChanged = fmt.Errorf("job changed while being processed")
+
+type Worker struct {
+	db        *gorm.DB
+	config    Config
+	processor Processor
+}
+
+func New(db *gorm.DB, config Config, processor Processor) *Worker {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 5 * time.Second
+	}
+	return &Worker{
+		db:        db,
+		config:    config,
+		processor: processor,
+	}
+}
+
+// Run processes jobs until the context is cancelled, waiting for the jobs in
+// progress to finish before returning.
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// jobs that were already claimed are not interrupted when the
+		// worker is stopped
+		processed, err := w.ProcessNext(context.Background())
+		if err != nil {
+			zap.L().Error("failed to process job", zap.Error(err))
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.config.PollInterval):
+		}
+	}
+}
+
+// ProcessNext claims the next job and processes it, returning false if there
+// was no job to claim.
+func (w *Worker) ProcessNext(ctx context.Context) (bool, error) {
+	job, err := model.ClaimNextJob(w.db)
+	if gorm.IsRecordNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	log := zap.L().With(
+		zap.String("job_id", job.ID.String()),
+		zap.String("project_id", job.ProjectID.String()),
+	)
+	log.Info("claimed job")
+
+	if err := w.process(ctx, job); err != nil {
+		if err == errJobChanged {
+			log.Warn("job changed while being processed, dropping it")
+			return true, nil
+		}
+
+		log.Error("job failed", zap.Error(err))
+		if _, failErr := model.FailJob(w.db, job, err.Error()); failErr != nil {
+			return true, failErr
+		}
+		return true, nil
+	}
+
+	log.Info("job finished")
+	return true, nil
+}
+
+func (w *Worker) process(ctx context.Context, job *model.Job) error {
+	if w.config.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.config.JobTimeout)
+		defer cancel()
+	}
+
+	project, err := model.FindProjectByID(w.db, job.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uuid.UUID, len(job.Params.Images))
+	for i, img := range job.Params.Images {
+		ids[i] = img.ID
+	}
+	images, err := model.FindImagesByIDs(w.db, ids)
+	if err != nil {
+		return err
+	}
+
+	// progress is reported on the job as it is made, the job itself is
+	// updated so later changes are checked against the latest version.
+	var mu sync.Mutex
+	var progressErr error
+	report := func(progress int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if progressErr != nil || job.Progress != nil && *job.Progress == progress {
+			return
+		}
+		ok, err := model.SetJobProgress(w.db, job, progress)
+		if err == nil && !ok {
+			err = errJobChanged
+		}
+		progressErr = err
+	}
+
+	res, err := w.processor.Process(ctx, &Input{
+		Job:     job,
+		Project: project,
+		Images:  images,
+	}, report)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if progressErr != nil {
+		return progressErr
+	}
+
+	return database.Transact(ctx, w.db, func(ctx context.Context, tx *gorm.DB) error {
+		for imageID, labels := range res.Images {
+			if err := model.SetImageLabels(tx, imageID, labels); err != nil {
+				return err
+			}
+		}
+
+		ok, err := model.FinishJob(tx, job, res.ResultImageURL)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errJobChanged
+		}
+		return nil
+	})
+}
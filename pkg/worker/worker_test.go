@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	gomigrate "github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+
+	"github.com/caquillo07/pyvinci-server/database"
+	"github.com/caquillo07/pyvinci-server/pkg/model"
+)
+
+// testDB connects to the database in PYVINCI_TEST_DATABASE and migrates it,
+// skipping the test when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("PYVINCI_TEST_DATABASE")
+	if dsn == "" {
+		t.Skip("PYVINCI_TEST_DATABASE is not set")
+	}
+
+	db, err := database.Open(database.Config{Driver: "postgres", ConnectionString: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := postgres.WithInstance(db.DB(), &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := gomigrate.NewWithDatabaseInstance("file://../../migrations", "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil && err != gomigrate.ErrNoChange {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// pendingJob creates a project with a single image and a job for it, ready
+// to be claimed. The job gets the highest priority, so it is claimed before
+// the jobs other tests may have left in the queue.
+func pendingJob(t *testing.T, db *gorm.DB) (*model.Job, *model.Image) {
+	user := &model.User{Username: "worker-" + uuid.Must(uuid.NewV4()).String()[:8]}
+	if err := model.CreateUser(db, user, "password"); err != nil {
+		t.Fatal(err)
+	}
+	project := &model.Project{UserID: user.ID, Name: "worker", Keywords: []string{"kite"}}
+	if err := model.CreateProject(db, project); err != nil {
+		t.Fatal(err)
+	}
+	img := &model.Image{ProjectID: project.ID, URL: "https://example.com/" + user.Username + ".jpg"}
+	if err := model.CreateImage(db, img); err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := model.NewJobParams(db, project, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := model.CreateNewJob(db, project.ID, 1<<30, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(job).Update("status", model.JobStatusPendingLabels).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job, img
+}
+
+// processNext runs the worker over the next job, which must be the given one.
+func processNext(t *testing.T, w *Worker, job *model.Job) *model.Job {
+	processed, err := w.ProcessNext(context.Background())
+	if err != nil || !processed {
+		t.Fatalf("ProcessNext() = %v, %v, want a processed job", processed, err)
+	}
+
+	got, err := model.FindJobByID(w.db, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status == model.JobStatusPendingLabels {
+		t.Fatal("ProcessNext() claimed another job")
+	}
+	return got
+}
+
+func TestProcessNextFinishes(t *testing.T) {
+	db := testDB(t)
+	job, img := pendingJob(t, db)
+
+	got := processNext(t, New(db, Config{}, FakeProcessor{}), job)
+	if got.Status != model.JobStatusFinished {
+		t.Fatalf("job status = %s, want %s", got.Status, model.JobStatusFinished)
+	}
+	if got.Progress == nil || *got.Progress != 100 {
+		t.Errorf("job progress = %v, want 100", got.Progress)
+	}
+	if got.ResultImageURL == nil || *got.ResultImageURL != img.URL {
+		t.Errorf("job result image = %v, want %s", got.ResultImageURL, img.URL)
+	}
+
+	// the image got the labels of the processor
+	res, err := FakeProcessor{}.Process(context.Background(), &Input{Job: got, Images: []*model.Image{img}}, func(int) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := res.Images[img.ID]
+	labeled, err := model.FindImageByID(db, img.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string(labeled.LabelsThings), want.Things) ||
+		!reflect.DeepEqual([]string(labeled.LabelsStuff), want.Stuff) ||
+		!reflect.DeepEqual([]string(labeled.MasksLabels), want.MasksLabels) {
+		t.Errorf("image labels = %v, %v, %v, want %v, %v, %v",
+			labeled.LabelsThings, labeled.LabelsStuff, labeled.MasksLabels,
+			want.Things, want.Stuff, want.MasksLabels)
+	}
+
+	// watchers are told the job finished
+	events, err := model.JobEventsSince(db, job.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || !events[len(events)-1].IsFinal() {
+		t.Errorf("job events = %+v, want a final one last", events)
+	}
+}
+
+// failingProcessor fails every job with err.
+type failingProcessor struct {
+	err error
+}
+
+func (p failingProcessor) Process(context.Context, *Input, func(int)) (*Result, error) {
+	return nil, p.err
+}
+
+func TestProcessNextFails(t *testing.T) {
+	db := testDB(t)
+	job, _ := pendingJob(t, db)
+
+	got := processNext(t, New(db, Config{}, failingProcessor{errors.New("model crashed")}), job)
+	if got.Status != model.JobStatusFailed {
+		t.Fatalf("job status = %s, want %s", got.Status, model.JobStatusFailed)
+	}
+	if got.Error == nil || *got.Error != "model crashed" {
+		t.Errorf("job error = %v, want model crashed", got.Error)
+	}
+}